@@ -0,0 +1,327 @@
+package cliware
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoEndpoints is returned by Balancer when there is no endpoint request
+// can be sent to.
+var ErrNoEndpoints = errors.New("cliware: no endpoints available")
+
+// BalanceStrategy defines how Balancer chooses endpoint for request.
+type BalanceStrategy int
+
+const (
+	// RoundRobin sends requests to endpoints in turn.
+	RoundRobin BalanceStrategy = iota
+	// Random sends each request to randomly chosen endpoint.
+	Random
+	// LeastInFlight sends request to endpoint with fewest requests whose
+	// responses are not closed yet.
+	LeastInFlight
+	// ConsistentHash sends requests with the same key (see Balancer.Key) to
+	// the same endpoint, as long as it is healthy.
+	ConsistentHash
+)
+
+// Balancer is Middleware that distributes requests over pool of endpoints
+// (base URLs) by rewriting scheme and host of request URL. Endpoint path, if
+// any, is prepended to request path.
+//
+// Balancer passively tracks health of endpoints. Endpoint that fails
+// MaxFailures times in a row is ejected from pool for Cooldown duration. If
+// all endpoints are ejected, all of them are used again. When request fails
+// with error, it might be sent to next endpoint (see ShouldFailover), until
+// every endpoint has been tried once.
+type Balancer struct {
+	// Strategy is strategy used to choose endpoint.
+	Strategy BalanceStrategy
+
+	// Key returns key used by ConsistentHash strategy. If nil, request URL
+	// path is used.
+	Key func(req *http.Request) string
+
+	// MaxFailures is number of consecutive failures after which endpoint is
+	// ejected. Values lower than 1 mean 1.
+	MaxFailures int
+
+	// Cooldown is duration for which failing endpoint is ejected.
+	Cooldown time.Duration
+
+	// IsFailure decides if result of request counts as endpoint failure.
+	// If nil, errors and 502, 503 and 504 responses are failures.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// ShouldFailover decides if request that failed with provided error
+	// should be sent to next endpoint. If nil, idempotent requests (see
+	// IsIdempotent) fail over on any error, while other requests fail over
+	// only if connection could not be established, so they are never sent
+	// twice. Request never fails over if its context is done. Request body
+	// is buffered (see RewindableBody) only for requests that might fail
+	// over on any error.
+	ShouldFailover func(req *http.Request, err error) bool
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	counter   int
+	random    *rand.Rand
+}
+
+// endpoint holds state of single Balancer endpoint.
+type endpoint struct {
+	url          *url.URL
+	inFlight     int
+	failures     int
+	ejectedUntil time.Time
+}
+
+// NewBalancer creates Balancer with provided strategy and endpoints. Each
+// endpoint has to be absolute URL, e.g. "https://replica1.example.com/api".
+func NewBalancer(strategy BalanceStrategy, endpoints ...string) (*Balancer, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	b := &Balancer{
+		Strategy:    strategy,
+		MaxFailures: 1,
+		Cooldown:    30 * time.Second,
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, e := range endpoints {
+		u, err := url.Parse(e)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, errors.New("cliware: endpoint is not absolute URL: " + e)
+		}
+		b.endpoints = append(b.endpoints, &endpoint{url: u})
+	}
+	return b, nil
+}
+
+// Healthy returns endpoints that are currently not ejected.
+func (b *Balancer) Healthy() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var healthy []string
+	for _, e := range b.endpoints {
+		if !now.Before(e.ejectedUntil) {
+			healthy = append(healthy, e.url.String())
+		}
+	}
+	return healthy
+}
+
+// Exec is implementation of Middleware interface.
+func (b *Balancer) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		if len(b.endpoints) > 1 && (b.ShouldFailover != nil || IsIdempotent(req)) {
+			if err := RewindableBody(req); err != nil {
+				return nil, err
+			}
+		}
+		tried := make(map[*endpoint]bool, len(b.endpoints))
+		for {
+			e := b.pick(req, tried)
+			if e == nil {
+				if err == nil {
+					err = ErrNoEndpoints
+				}
+				return resp, err
+			}
+			tried[e] = true
+			attempt, cloneErr := attemptRequest(req)
+			if cloneErr != nil {
+				b.done(e, nil, nil)
+				return nil, cloneErr
+			}
+			rewriteURL(attempt, e.url)
+
+			resp, err = next.Handle(attempt)
+			b.done(e, resp, err)
+			if err == nil || !b.shouldFailover(req, err) {
+				return resp, err
+			}
+			DrainBody(resp)
+		}
+	})
+}
+
+// pick chooses endpoint for request, skipping already tried endpoints,
+// and marks it as in flight.
+func (b *Balancer) pick(req *http.Request, tried map[*endpoint]bool) *endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var healthy, all []*endpoint
+	for _, e := range b.endpoints {
+		if tried[e] {
+			continue
+		}
+		all = append(all, e)
+		if !now.Before(e.ejectedUntil) {
+			healthy = append(healthy, e)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = all
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var chosen *endpoint
+	switch b.Strategy {
+	case Random:
+		chosen = candidates[b.random.Intn(len(candidates))]
+	case LeastInFlight:
+		chosen = candidates[0]
+		for _, e := range candidates[1:] {
+			if e.inFlight < chosen.inFlight {
+				chosen = e
+			}
+		}
+	case ConsistentHash:
+		chosen = rendezvous(b.key(req), candidates)
+	default:
+		chosen = candidates[b.counter%len(candidates)]
+		b.counter++
+	}
+	chosen.inFlight++
+	return chosen
+}
+
+// done records result of request sent to endpoint. Endpoint stays in flight
+// until response body is closed.
+func (b *Balancer) done(e *endpoint, resp *http.Response, err error) {
+	release := func() {
+		b.mu.Lock()
+		e.inFlight--
+		b.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	if b.isFailure(resp, err) {
+		e.failures++
+		maxFailures := b.MaxFailures
+		if maxFailures < 1 {
+			maxFailures = 1
+		}
+		if e.failures >= maxFailures {
+			e.ejectedUntil = time.Now().Add(b.Cooldown)
+			e.failures = 0
+		}
+	} else {
+		e.failures = 0
+	}
+	b.mu.Unlock()
+
+	if resp == nil || resp.Body == nil {
+		release()
+		return
+	}
+	var once sync.Once
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() { once.Do(release) }}
+}
+
+func (b *Balancer) isFailure(resp *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(resp, err)
+	}
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (b *Balancer) shouldFailover(req *http.Request, err error) bool {
+	if b.ShouldFailover != nil {
+		return b.ShouldFailover(req, err)
+	}
+	if req.Context().Err() != nil || !bodyRewindable(req) {
+		return false
+	}
+	return IsIdempotent(req) || isDialError(err)
+}
+
+// attemptRequest returns copy of request for single attempt. Body that can
+// not be reopened is passed to attempt as is, so such request can be sent
+// only once.
+func attemptRequest(req *http.Request) (*http.Request, error) {
+	if bodyRewindable(req) {
+		return CloneRequest(req.Context(), req)
+	}
+	attempt := req.WithContext(req.Context())
+	attempt.Header = cloneHeader(req.Header)
+	return attempt, nil
+}
+
+// bodyRewindable checks if request body can be sent again.
+func bodyRewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// isDialError checks if error means connection to server could not be
+// established, so request was not sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (b *Balancer) key(req *http.Request) string {
+	if b.Key != nil {
+		return b.Key(req)
+	}
+	if req.URL == nil {
+		return ""
+	}
+	return req.URL.Path
+}
+
+// rendezvous chooses endpoint for key using rendezvous (highest random
+// weight) hashing, so only keys of removed endpoint move when pool changes.
+func rendezvous(key string, candidates []*endpoint) *endpoint {
+	var chosen *endpoint
+	var best uint64
+	for _, e := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(e.url.String()))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		if weight := h.Sum64(); chosen == nil || weight > best {
+			chosen, best = e, weight
+		}
+	}
+	return chosen
+}
+
+// rewriteURL changes request URL to point to provided base URL.
+func rewriteURL(req *http.Request, base *url.URL) {
+	u := *req.URL
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+	if base.Path != "" && base.Path != "/" {
+		u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+		u.RawPath = ""
+	}
+	req.URL = &u
+	req.Host = ""
+}
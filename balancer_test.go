@@ -0,0 +1,168 @@
+package cliware_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	c "github.com/delicb/cliware"
+)
+
+// hostRecorder returns handler that records host of each request and fails
+// requests sent to provided failing hosts.
+func hostRecorder(hosts *[]string, failing ...string) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		*hosts = append(*hosts, req.URL.Host)
+		for _, f := range failing {
+			if req.URL.Host == f {
+				return nil, errors.New("connection refused")
+			}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	})
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	b, err := c.NewBalancer(c.RoundRobin, "http://a", "http://b")
+	if err != nil {
+		t.Fatal("NewBalancer returned error: ", err)
+	}
+	var hosts []string
+	handler := b.Exec(hostRecorder(&hosts))
+	for i := 0; i < 4; i++ {
+		req, _ := http.NewRequest("GET", "http://placeholder/path", nil)
+		if _, err := handler.Handle(req); err != nil {
+			t.Fatal("Handle returned error: ", err)
+		}
+	}
+	expected := []string{"a", "b", "a", "b"}
+	for i := range expected {
+		if hosts[i] != expected[i] {
+			t.Fatalf("Expected hosts %v, got: %v", expected, hosts)
+		}
+	}
+}
+
+func TestBalancerBasePath(t *testing.T) {
+	b, _ := c.NewBalancer(c.RoundRobin, "https://a/api/")
+	var path string
+	handler := b.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		path = req.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	}))
+	req, _ := http.NewRequest("GET", "http://placeholder/users?id=1", nil)
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if path != "https://a/api/users?id=1" {
+		t.Errorf("Wrong rewritten URL: %s", path)
+	}
+}
+
+func TestBalancerFailoverAndEject(t *testing.T) {
+	b, _ := c.NewBalancer(c.RoundRobin, "http://a", "http://b")
+	b.Cooldown = time.Hour
+	var hosts []string
+	handler := b.Exec(hostRecorder(&hosts, "a"))
+
+	req, _ := http.NewRequest("GET", "http://placeholder/", nil)
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error after failover: ", err)
+	}
+	if len(hosts) != 2 || hosts[0] != "a" || hosts[1] != "b" {
+		t.Errorf("Expected failover from a to b, got: %v", hosts)
+	}
+	healthy := b.Healthy()
+	if len(healthy) != 1 || healthy[0] != "http://b" {
+		t.Errorf("Expected only b to be healthy, got: %v", healthy)
+	}
+}
+
+func TestBalancerAllFailing(t *testing.T) {
+	b, _ := c.NewBalancer(c.LeastInFlight, "http://a", "http://b")
+	var hosts []string
+	handler := b.Exec(hostRecorder(&hosts, "a", "b"))
+	req, _ := http.NewRequest("GET", "http://placeholder/", nil)
+	if _, err := handler.Handle(req); err == nil {
+		t.Error("Expected error when all endpoints fail.")
+	}
+	if len(hosts) != 2 {
+		t.Errorf("Expected each endpoint to be tried once, got: %v", hosts)
+	}
+}
+
+func TestBalancerConsistentHash(t *testing.T) {
+	b, _ := c.NewBalancer(c.ConsistentHash, "http://a", "http://b", "http://c")
+	var hosts []string
+	handler := b.Exec(hostRecorder(&hosts))
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "http://placeholder/tenant/42", nil)
+		if _, err := handler.Handle(req); err != nil {
+			t.Fatal("Handle returned error: ", err)
+		}
+	}
+	for _, h := range hosts {
+		if h != hosts[0] {
+			t.Fatalf("Same key sent to different endpoints: %v", hosts)
+		}
+	}
+}
+
+func TestBalancerInvalidEndpoint(t *testing.T) {
+	if _, err := c.NewBalancer(c.Random, "not-absolute"); err == nil {
+		t.Error("Expected error for relative endpoint.")
+	}
+	if _, err := c.NewBalancer(c.Random); err != c.ErrNoEndpoints {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrNoEndpoints, err)
+	}
+}
+
+func TestBalancerFailoverNonIdempotent(t *testing.T) {
+	b, _ := c.NewBalancer(c.RoundRobin, "http://a", "http://b")
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	for _, e := range []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("timeout awaiting response headers"), 1},
+		{dialErr, 2},
+	} {
+		var attempts int
+		handler := b.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, e.err
+		}))
+		req, _ := http.NewRequest("POST", "http://placeholder/orders", strings.NewReader("order"))
+		if _, err := handler.Handle(req); err == nil {
+			t.Error("Expected error from handler.")
+		}
+		if attempts != e.expected {
+			t.Errorf("Expected %d attempts for %q, got: %d", e.expected, e.err, attempts)
+		}
+	}
+}
+
+func TestBalancerStreamingBody(t *testing.T) {
+	b, _ := c.NewBalancer(c.RoundRobin, "http://a", "http://b")
+	body := ioutil.NopCloser(strings.NewReader("order"))
+	var attempts int
+	handler := b.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if req.Body != body {
+			t.Error("Streaming body was buffered.")
+		}
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}))
+	req, _ := http.NewRequest("POST", "http://placeholder/orders", nil)
+	req.Body = body
+	if _, err := handler.Handle(req); err == nil {
+		t.Error("Expected error from handler.")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected single attempt for body that can not be resent, got: %d", attempts)
+	}
+}
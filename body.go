@@ -0,0 +1,106 @@
+package cliware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// maxDrainBytes is maximal number of bytes that will be read from response
+// body that is being discarded. Reading rest of the body allows underlying
+// connection to be reused, but it is not worth reading huge bodies.
+const maxDrainBytes = 64 << 10
+
+// RewindableBody makes sure request body can be read multiple times. If
+// request already has GetBody function set (http.NewRequest does that for
+// common body types) nothing is done. Otherwise, entire body is read into
+// memory and both Body and GetBody are set to read from it.
+//
+// It is intended for middlewares that need to send same request more than
+// once, like retries or hedging.
+func RewindableBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	closeErr := req.Body.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	req.ContentLength = int64(len(data))
+	return nil
+}
+
+// CloneRequest returns copy of provided request with provided context.
+// Headers are copied, so they can be safely modified on returned request, and
+// body is obtained from GetBody, so each clone reads its own body. If request
+// has body that can not be reopened, error is returned. Use RewindableBody
+// to make sure that is not the case.
+func CloneRequest(ctx context.Context, req *http.Request) (*http.Request, error) {
+	clone := req.WithContext(ctx)
+	clone.Header = cloneHeader(req.Header)
+	if req.Body == nil || req.Body == http.NoBody {
+		return clone, nil
+	}
+	if req.GetBody == nil {
+		return nil, ErrBodyNotRewindable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	clone.Body = body
+	return clone, nil
+}
+
+// ErrBodyNotRewindable is returned when request needs to be sent more than
+// once, but its body can not be reopened.
+var ErrBodyNotRewindable = errors.New("cliware: request body can not be reopened")
+
+// DrainBody reads (up to reasonable limit) and closes body of provided
+// response. It is intended for discarding responses that will not be
+// returned to caller, so that underlying connection can be reused.
+// It is safe to call with nil response or response without body.
+func DrainBody(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.CopyN(ioutil.Discard, resp.Body, maxDrainBytes)
+	_ = resp.Body.Close()
+}
+
+// cloneHeader returns deep copy of provided header.
+func cloneHeader(h http.Header) http.Header {
+	if h == nil {
+		return nil
+	}
+	clone := make(http.Header, len(h))
+	for k, v := range h {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// cancelOnClose is response body wrapper that cancels context in which
+// response was obtained once body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close is implementation of io.Closer interface.
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
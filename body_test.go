@@ -0,0 +1,54 @@
+package cliware_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestRewindableBody(t *testing.T) {
+	req := c.EmptyRequest()
+	req.Body = ioutil.NopCloser(strings.NewReader("payload"))
+	if err := c.RewindableBody(req); err != nil {
+		t.Fatal("RewindableBody returned error: ", err)
+	}
+	for i := 0; i < 2; i++ {
+		clone, err := c.CloneRequest(context.Background(), req)
+		if err != nil {
+			t.Fatal("CloneRequest returned error: ", err)
+		}
+		body, _ := ioutil.ReadAll(clone.Body)
+		if string(body) != "payload" {
+			t.Errorf("Wrong body of cloned request: %q", body)
+		}
+	}
+	if req.ContentLength != int64(len("payload")) {
+		t.Errorf("Wrong content length: %d", req.ContentLength)
+	}
+}
+
+func TestCloneRequestHeaders(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	req.Header.Set("X-Test", "original")
+	clone, err := c.CloneRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal("CloneRequest returned error: ", err)
+	}
+	clone.Header.Set("X-Test", "changed")
+	if req.Header.Get("X-Test") != "original" {
+		t.Error("Changing header of cloned request changed original request.")
+	}
+}
+
+func TestCloneRequestNotRewindable(t *testing.T) {
+	req := c.EmptyRequest()
+	req.Body = ioutil.NopCloser(strings.NewReader("payload"))
+	_, err := c.CloneRequest(context.Background(), req)
+	if err != c.ErrBodyNotRewindable {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrBodyNotRewindable, err)
+	}
+}
@@ -0,0 +1,210 @@
+package cliware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is error returned by Chaos middleware for rules that
+// inject connection error without specifying one.
+var ErrInjectedFault = errors.New("cliware: injected connection error")
+
+// FaultRule describes fault Chaos middleware injects into requests that
+// match rule targeting. All targeting fields that are set have to match.
+type FaultRule struct {
+	// Hosts are glob patterns (as used by path.Match, e.g. "*.example.com")
+	// for request host. Empty means any host.
+	Hosts []string
+	// PathPrefix is required prefix of request URL path.
+	PathPrefix string
+	// Methods are HTTP methods rule applies to. Empty means any method.
+	Methods []string
+
+	// Probability is probability (between 0 and 1) that fault is injected
+	// into matching request.
+	Probability float64
+
+	// Latency is delay added before request is sent.
+	Latency time.Duration
+	// ConnectionError makes request fail with Err (or ErrInjectedFault if
+	// Err is nil) instead of being sent.
+	ConnectionError bool
+	// Err is error returned when ConnectionError is set.
+	Err error
+	// StatusCode, if set, makes middleware return synthetic response with
+	// this status code instead of sending request.
+	StatusCode int
+	// TruncateAfter, if positive, makes response body fail with
+	// io.ErrUnexpectedEOF after this number of bytes.
+	TruncateAfter int64
+	// SlowRead is delay added to each read of response body.
+	SlowRead time.Duration
+}
+
+// matches checks if rule targeting matches request.
+func (r *FaultRule) matches(req *http.Request) bool {
+	if len(r.Hosts) > 0 && !HostGlob(r.Hosts...)(req) {
+		return false
+	}
+	if r.PathPrefix != "" && !PathPrefix(r.PathPrefix)(req) {
+		return false
+	}
+	if len(r.Methods) > 0 && !Methods(r.Methods...)(req) {
+		return false
+	}
+	return true
+}
+
+// Chaos is Middleware that injects faults (latency, connection errors,
+// synthetic status codes, truncated or slow bodies) into requests for
+// testing resilience of clients. Rules can be changed and middleware can be
+// enabled or disabled at any time, also while requests are running.
+//
+// For each request, first rule whose targeting matches and whose
+// probability roll succeeds is applied.
+type Chaos struct {
+	mu      sync.Mutex
+	rules   []FaultRule
+	enabled bool
+	random  *rand.Rand
+}
+
+// NewChaos creates enabled Chaos middleware with provided rules.
+func NewChaos(rules ...FaultRule) *Chaos {
+	return &Chaos{
+		rules:   rules,
+		enabled: true,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetRules replaces all rules.
+func (c *Chaos) SetRules(rules ...FaultRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = rules
+}
+
+// Enable turns fault injection on.
+func (c *Chaos) Enable() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = true
+}
+
+// Disable turns fault injection off. Requests are passed to next handler
+// untouched.
+func (c *Chaos) Disable() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = false
+}
+
+// Exec is implementation of Middleware interface.
+func (c *Chaos) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		rule := c.pick(req)
+		if rule == nil {
+			return next.Handle(req)
+		}
+
+		if rule.Latency > 0 {
+			timer := time.NewTimer(rule.Latency)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+		if rule.ConnectionError {
+			if rule.Err != nil {
+				return nil, rule.Err
+			}
+			return nil, ErrInjectedFault
+		}
+		if rule.StatusCode != 0 {
+			resp = &http.Response{
+				Status:     fmt.Sprintf("%d %s", rule.StatusCode, http.StatusText(rule.StatusCode)),
+				StatusCode: rule.StatusCode,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     make(http.Header),
+				Body:       http.NoBody,
+				Request:    req,
+			}
+		} else {
+			resp, err = next.Handle(req)
+		}
+		if resp != nil && resp.Body != nil && (rule.TruncateAfter > 0 || rule.SlowRead > 0) {
+			resp.Body = &faultyBody{
+				ReadCloser: resp.Body,
+				req:        req,
+				remaining:  rule.TruncateAfter,
+				truncate:   rule.TruncateAfter > 0,
+				delay:      rule.SlowRead,
+			}
+		}
+		return resp, err
+	})
+}
+
+// pick returns copy of rule to apply to request, or nil.
+func (c *Chaos) pick(req *http.Request) *FaultRule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return nil
+	}
+	for i := range c.rules {
+		if !c.rules[i].matches(req) {
+			continue
+		}
+		if c.random.Float64() < c.rules[i].Probability {
+			rule := c.rules[i]
+			return &rule
+		}
+	}
+	return nil
+}
+
+// faultyBody is response body that reads slowly and/or fails after some
+// number of bytes.
+type faultyBody struct {
+	io.ReadCloser
+	req       *http.Request
+	remaining int64
+	truncate  bool
+	delay     time.Duration
+}
+
+// Read is implementation of io.Reader interface.
+func (b *faultyBody) Read(p []byte) (int, error) {
+	if b.delay > 0 {
+		timer := time.NewTimer(b.delay)
+		select {
+		case <-timer.C:
+		case <-b.req.Context().Done():
+			timer.Stop()
+			return 0, b.req.Context().Err()
+		}
+	}
+	if !b.truncate {
+		return b.ReadCloser.Read(p)
+	}
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,86 @@
+package cliware_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestChaosConnectionError(t *testing.T) {
+	chaos := c.NewChaos(c.FaultRule{
+		Hosts:           []string{"*.internal"},
+		Methods:         []string{"GET"},
+		Probability:     1,
+		ConnectionError: true,
+	})
+	handler, handlerCalled := createHandler()
+	req, _ := http.NewRequest("GET", "http://api.internal:8080/x", nil)
+	if _, err := chaos.Exec(handler).Handle(req); err != c.ErrInjectedFault {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrInjectedFault, err)
+	}
+	if *handlerCalled {
+		t.Error("Handler called despite injected connection error.")
+	}
+
+	req, _ = http.NewRequest("POST", "http://api.internal/x", nil)
+	if _, err := chaos.Exec(handler).Handle(req); err != nil {
+		t.Error("Rule applied to request with different method: ", err)
+	}
+}
+
+func TestChaosStatusCodeAndRuntimeSwitch(t *testing.T) {
+	chaos := c.NewChaos(c.FaultRule{PathPrefix: "/api", Probability: 1, StatusCode: http.StatusServiceUnavailable})
+	handler := chaos.Exec(bodyHandler("ok"))
+	req, _ := http.NewRequest("GET", "http://localhost/api/items", nil)
+	resp, err := handler.Handle(req)
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected synthetic 503, got: %v, %v", resp, err)
+	}
+
+	chaos.Disable()
+	resp, _ = handler.Handle(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected real response when disabled, got: %d", resp.StatusCode)
+	}
+	chaos.Enable()
+	chaos.SetRules(c.FaultRule{Probability: 0, StatusCode: http.StatusServiceUnavailable})
+	resp, _ = handler.Handle(req)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Rule with zero probability applied, got: %d", resp.StatusCode)
+	}
+}
+
+func TestChaosTruncatedBody(t *testing.T) {
+	chaos := c.NewChaos(c.FaultRule{Probability: 1, TruncateAfter: 3})
+	resp, err := chaos.Exec(bodyHandler("0123456789")).Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != io.ErrUnexpectedEOF || string(body) != "012" {
+		t.Errorf("Expected truncated body, got: %q, %v", body, err)
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	chaos := c.NewChaos(c.FaultRule{Probability: 1, Latency: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := chaos.Exec(bodyHandler("ok")).Handle(c.EmptyRequest().WithContext(ctx))
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.DeadlineExceeded, err)
+	}
+
+	chaos.SetRules(c.FaultRule{Probability: 1, SlowRead: 5 * time.Millisecond})
+	start := time.Now()
+	resp, _ := chaos.Exec(bodyHandler("ok")).Handle(c.EmptyRequest())
+	ioutil.ReadAll(resp.Body)
+	if time.Since(start) < 5*time.Millisecond {
+		t.Error("Body read was not slowed down.")
+	}
+}
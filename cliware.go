@@ -3,6 +3,7 @@ package cliware
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -52,6 +53,23 @@ func (mf MiddlewareFunc) Exec(handler Handler) Handler {
 	return mf(handler)
 }
 
+// NamedMiddleware is Middleware with name attached. Name can be used to
+// disable middleware for single request (see WithoutMiddlewares).
+type NamedMiddleware struct {
+	Name       string
+	Middleware Middleware
+}
+
+// Named returns provided middleware with name attached to it.
+func Named(name string, m Middleware) *NamedMiddleware {
+	return &NamedMiddleware{Name: name, Middleware: m}
+}
+
+// Exec is implementation of Middleware interface.
+func (n *NamedMiddleware) Exec(next Handler) Handler {
+	return n.Middleware.Exec(next)
+}
+
 // RequestProcessor is function for modification of HTTP request.
 // It is intended as form of simple Middleware for middlewares that only need
 // to change request that is being sent. Provided request can be modified
@@ -64,7 +82,7 @@ func (rp RequestProcessor) Exec(handler Handler) Handler {
 	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
 		err = rp(req)
 		if err != nil {
-			return nil, err
+			return nil, wrapProcessorError(req, RequestPhase, err)
 		}
 
 		resp, err = handler.Handle(req)
@@ -89,12 +107,67 @@ func (rp ResponseProcessor) Exec(handler Handler) Handler {
 		resp, err = handler.Handle(req)
 		newErr := rp(resp, err)
 		if newErr != nil {
+			if newErr != err {
+				newErr = wrapProcessorError(req, ResponsePhase, newErr)
+			}
 			return resp, newErr
 		}
 		return resp, err
 	})
 }
 
+// ExchangeProcessor is function for inspection and modification of HTTP
+// response that also receives request response belongs to. Provided request
+// is request as it was passed to next handler, after all earlier request
+// middlewares ran, so it is available even if there is no response because
+// of error. Returned response and error replace provided ones. Processor
+// that does not want to change anything should return provided response and
+// error.
+type ExchangeProcessor func(req *http.Request, resp *http.Response, err error) (*http.Response, error)
+
+// Exec is implementation of Middleware interface.
+func (ep ExchangeProcessor) Exec(handler Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		resp, err = handler.Handle(req)
+		newResp, newErr := ep(req, resp, err)
+		if newErr != nil && newErr != err {
+			newErr = wrapProcessorError(req, ResponsePhase, newErr)
+		}
+		return newResp, newErr
+	})
+}
+
+// ErrAbort can be returned by ShortCircuitProcessor (or any other middleware)
+// to deliberately stop chain execution. It is returned to caller like any
+// other error, so caller can tell abort from failure with errors.Is.
+var ErrAbort = errors.New("cliware: request aborted")
+
+// ShortCircuitProcessor is function for modification of HTTP request that
+// can also respond to it. It is intended as form of simple Middleware for
+// caches, mocks and stubs. If it returns response, rest of the chain is
+// skipped and that response is returned to caller, with request attached if
+// response does not already have one. If it returns error (e.g. ErrAbort),
+// chain execution stops and error is returned to caller. If it returns
+// neither response nor error, request is passed to next handler.
+type ShortCircuitProcessor func(req *http.Request) (*http.Response, error)
+
+// Exec is implementation of Middleware interface.
+func (sp ShortCircuitProcessor) Exec(handler Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		resp, err = sp(req)
+		if err != nil {
+			return nil, wrapProcessorError(req, RequestPhase, err)
+		}
+		if resp != nil {
+			if resp.Request == nil {
+				resp.Request = req
+			}
+			return resp, nil
+		}
+		return handler.Handle(req)
+	})
+}
+
 // ContextProcessor is function for managing request context.
 // It is intended as form of simple middleware for middlewares that only
 // need to modify context before sending request.
@@ -115,6 +188,8 @@ func (cp ContextProcessor) Exec(handler Handler) Handler {
 type Chain struct {
 	middlewares []Middleware
 	parent      Middleware
+	name        string
+	observer    TraceObserver
 }
 
 // NewChain creates and returns middleware chain with provided middlewares
@@ -134,6 +209,8 @@ func (c *Chain) Copy() *Chain {
 	return &Chain{
 		middlewares: middlewareCopy,
 		parent:      nil,
+		name:        c.name,
+		observer:    c.observer,
 	}
 }
 
@@ -158,18 +235,69 @@ func (c *Chain) Parent() Middleware {
 
 // Exec is implementation of Middleware interface that executes all middlewares
 // in chain, including parent middleware.
+//
+// Returned handler honors overrides attached to request context with
+// WithMiddlewares and WithoutMiddlewares. Requests without overrides use
+// handler composed once, while for requests with overrides handler is
+// composed again on each request.
 func (c *Chain) Exec(handler Handler) Handler {
+	handler = trackResponses(handler)
+	var owner *transportOwner
+	if c.traceObserver() != nil {
+		owner = &transportOwner{chain: c}
+		handler = owner.wrap(handler)
+	}
+	composed := c.compose(handler, nil)
+	return HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		if owner != nil {
+			req = owner.claim(req)
+		}
+		o := overridesFromRequest(req)
+		if o == nil {
+			return composed.Handle(req)
+		}
+		finalHandler := handler
+		if len(o.extra) > 0 {
+			for i := len(o.extra) - 1; i >= 0; i-- {
+				finalHandler = o.extra[i].Exec(finalHandler)
+			}
+			// extra middlewares are added only once, by outermost chain
+			req = req.WithContext(context.WithValue(req.Context(), overridesKey{}, &overrides{
+				disabled: o.disabled,
+			}))
+		}
+		if len(o.disabled) == 0 && len(o.extra) == 0 {
+			return composed.Handle(req)
+		}
+		return c.compose(finalHandler, o.disabled).Handle(req)
+	})
+}
+
+// compose wraps handler with middlewares of chain and its parents, skipping
+// named middlewares that are disabled.
+func (c *Chain) compose(handler Handler, disabled map[string]bool) Handler {
 	finalHandler := handler
 
 	// Make sure to run own middlewares first... Because of the way middlewares
 	// are composed, ones called first will override ones called later and
 	// we want to be able to override middlewares in child chain.
+	observer := c.traceObserver()
 	for i := len(c.middlewares) - 1; i >= 0; i-- {
-		finalHandler = c.middlewares[i].Exec(finalHandler)
+		m := c.middlewares[i]
+		if named, ok := m.(*NamedMiddleware); ok && disabled[named.Name] {
+			continue
+		}
+		if observer != nil {
+			m = &tracedMiddleware{chain: c, index: i, middleware: m, observer: observer}
+		}
+		finalHandler = m.Exec(finalHandler)
 	}
 
-	// if we have parent, make sure to call it too...
-	if c.parent != nil {
+	// if we have parent, make sure to call it too... Parent chain is composed
+	// directly, since its Exec would treat rest of this chain as final handler.
+	if parent, ok := c.parent.(*Chain); ok {
+		finalHandler = parent.compose(finalHandler, disabled)
+	} else if c.parent != nil {
 		finalHandler = c.parent.Exec(finalHandler)
 	}
 
@@ -191,6 +319,16 @@ func (c *Chain) UseRequest(m func(req *http.Request) error) {
 	c.Use(RequestProcessor(m))
 }
 
+// UseExchange adds provided function as exchange middleware.
+func (c *Chain) UseExchange(m func(req *http.Request, resp *http.Response, err error) (*http.Response, error)) {
+	c.Use(ExchangeProcessor(m))
+}
+
+// UseShortCircuit adds provided function as short circuit middleware.
+func (c *Chain) UseShortCircuit(m func(req *http.Request) (*http.Response, error)) {
+	c.Use(ShortCircuitProcessor(m))
+}
+
 // UseResponse add provided function as response middleware.
 func (c *Chain) UseResponse(m func(resp *http.Response, err error) error) {
 	c.Use(ResponseProcessor(m))
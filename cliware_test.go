@@ -3,8 +3,11 @@ package cliware_test
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
 
 	c "github.com/delicb/cliware"
@@ -260,6 +263,125 @@ func TestResponseProcessorWithError(t *testing.T) {
 	}
 }
 
+func TestUseExchange(t *testing.T) {
+	chain := c.NewChain()
+	chain.UseExchange(func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+		if req.URL.Host != "example.com" {
+			t.Error("Exchange processor did not receive modified request.")
+		}
+		return resp, fmt.Errorf("%s %s: %v", req.Method, req.URL, err)
+	})
+	chain.UseRequest(func(req *http.Request) error {
+		req.URL.Host = "example.com"
+		return nil
+	})
+	req, _ := http.NewRequest("GET", "http://localhost/path", nil)
+	_, err := chain.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})).Handle(req)
+	if err == nil || err.Error() != "GET http://example.com/path: connection refused" {
+		t.Error("Unexpected error: ", err)
+	}
+}
+
+func TestExchangeProcessorReplacesResponse(t *testing.T) {
+	replacement := &http.Response{StatusCode: http.StatusOK}
+	processor := c.ExchangeProcessor(func(req *http.Request, resp *http.Response, err error) (*http.Response, error) {
+		return replacement, nil
+	})
+	resp, err := c.NewChain(processor).Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("failed")
+	})).Handle(nil)
+	if err != nil {
+		t.Error("Expected error to be replaced, got: ", err)
+	}
+	if resp != replacement {
+		t.Error("Expected response to be replaced.")
+	}
+}
+
+func TestShortCircuitProcessorResponse(t *testing.T) {
+	chain := c.NewChain()
+	stub := &http.Response{StatusCode: http.StatusNoContent}
+	chain.UseShortCircuit(func(req *http.Request) (*http.Response, error) {
+		return stub, nil
+	})
+	handler, handlerCalled := createHandler()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	resp, err := chain.Exec(handler).Handle(req)
+	if err != nil {
+		t.Error("Handle returned error: ", err)
+	}
+	if resp != stub || resp.Request != req {
+		t.Error("Expected short circuit response with request attached.")
+	}
+	if *handlerCalled {
+		t.Error("Handler should not be called.")
+	}
+}
+
+func TestShortCircuitProcessorPassThrough(t *testing.T) {
+	processor := c.ShortCircuitProcessor(func(req *http.Request) (*http.Response, error) {
+		return nil, nil
+	})
+	handler, handlerCalled := createHandler()
+	if _, err := c.NewChain(processor).Exec(handler).Handle(nil); err != nil {
+		t.Error("Handle returned error: ", err)
+	}
+	if !*handlerCalled {
+		t.Error("Handler was not called.")
+	}
+}
+
+func TestShortCircuitProcessorAbort(t *testing.T) {
+	processor := c.ShortCircuitProcessor(func(req *http.Request) (*http.Response, error) {
+		return nil, fmt.Errorf("stub: %w", c.ErrAbort)
+	})
+	handler, handlerCalled := createHandler()
+	resp, err := c.NewChain(processor).Exec(handler).Handle(nil)
+	if resp != nil || !errors.Is(err, c.ErrAbort) {
+		t.Errorf("Expected abort error without response, got %v, %v", resp, err)
+	}
+	if *handlerCalled {
+		t.Error("Handler should not be called.")
+	}
+}
+
+func TestShortCircuitProcessorAbortWithResponseProcessor(t *testing.T) {
+	chain := c.NewChain(
+		c.ResponseProcessor(func(resp *http.Response, err error) error {
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode >= 400 {
+				return errors.New("unexpected status")
+			}
+			return nil
+		}),
+		c.ShortCircuitProcessor(func(req *http.Request) (*http.Response, error) {
+			return nil, c.ErrAbort
+		}),
+	)
+	handler, _ := createHandler()
+	if _, err := chain.Exec(handler).Handle(nil); err != c.ErrAbort {
+		t.Error("Expected abort error, got: ", err)
+	}
+}
+
+func TestShortCircuitProcessorWithError(t *testing.T) {
+	myErr := errors.New("my error")
+	processor := c.ShortCircuitProcessor(func(req *http.Request) (*http.Response, error) {
+		return nil, myErr
+	})
+	handler, handlerCalled := createHandler()
+	if _, err := c.NewChain(processor).Exec(handler).Handle(nil); err != myErr {
+		t.Error("Expected error from processor, got: ", err)
+	}
+	if *handlerCalled {
+		t.Error("Handler should not be called.")
+	}
+}
+
 func TestContextProcessor_Exec(t *testing.T) {
 	var processorCalled bool
 	processor := c.ContextProcessor(func(ctx context.Context) context.Context {
@@ -337,3 +459,15 @@ func createHandler() (handler c.Handler, called *bool) {
 	})
 	return handler, &handlerCalled
 }
+
+// bodyHandler returns handler that always responds with 200 OK and provided
+// body.
+func bodyHandler(body string) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
@@ -0,0 +1,14 @@
+// Package cliwaretest provides utilities for testing code built on top of
+// cliware, most notably scriptable mock Handler.
+//
+// Mock is used as final handler of middleware chain. Expected requests are
+// registered with matchers and canned responses and assertions report
+// expected requests that were never sent and requests nobody expected:
+//
+//	mock := cliwaretest.NewMock(t)
+//	mock.Expect(cliwaretest.Method("GET"), cliwaretest.Path("/users")).
+//		RespondJSON(200, users)
+//	resp, err := chain.Exec(mock).Handle(req)
+//	...
+//	mock.AssertExpectations()
+package cliwaretest
@@ -0,0 +1,126 @@
+package cliwaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Matcher decides if request matches expectation.
+type Matcher interface {
+	// Match returns true if provided request matches.
+	Match(req *http.Request) bool
+	// String returns description of matcher, used in assertion messages.
+	String() string
+}
+
+// Match creates Matcher from provided function. Description is used in
+// assertion messages.
+func Match(description string, match func(req *http.Request) bool) Matcher {
+	return funcMatcher{description: description, match: match}
+}
+
+// funcMatcher is Matcher implemented by function.
+type funcMatcher struct {
+	description string
+	match       func(req *http.Request) bool
+}
+
+// Match is implementation of Matcher interface.
+func (m funcMatcher) Match(req *http.Request) bool {
+	return m.match(req)
+}
+
+// String is implementation of Matcher interface.
+func (m funcMatcher) String() string {
+	return m.description
+}
+
+// Method matches requests with provided HTTP method.
+func Method(method string) Matcher {
+	return Match("method "+method, func(req *http.Request) bool {
+		return strings.EqualFold(requestMethod(req), method)
+	})
+}
+
+// Path matches requests with provided URL path.
+func Path(path string) Matcher {
+	return Match("path "+path, func(req *http.Request) bool {
+		return req.URL != nil && req.URL.Path == path
+	})
+}
+
+// Query matches requests that have query parameter with provided value.
+func Query(name, value string) Matcher {
+	return Match(fmt.Sprintf("query %s=%s", name, value), func(req *http.Request) bool {
+		if req.URL == nil {
+			return false
+		}
+		for _, v := range req.URL.Query()[name] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Header matches requests that have header with provided value.
+func Header(name, value string) Matcher {
+	return Match(fmt.Sprintf("header %s: %s", name, value), func(req *http.Request) bool {
+		for _, v := range req.Header[http.CanonicalHeaderKey(name)] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// JSONBody matches requests whose body is JSON document equal to provided
+// value when both are marshaled to JSON, so formatting and key order do not
+// matter. Request body is restored after it is read, so it can be read
+// again by the code under test or other matchers.
+func JSONBody(v interface{}) Matcher {
+	expected, err := normalizeJSON(v)
+	return Match(fmt.Sprintf("JSON body %v", expected), func(req *http.Request) bool {
+		if err != nil || req.Body == nil {
+			return false
+		}
+		data, readErr := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		if readErr != nil {
+			return false
+		}
+		var actual interface{}
+		if json.Unmarshal(data, &actual) != nil {
+			return false
+		}
+		return reflect.DeepEqual(actual, expected)
+	})
+}
+
+// normalizeJSON converts provided value to generic JSON representation.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// requestMethod returns request method, taking into account that empty
+// method means GET.
+func requestMethod(req *http.Request) string {
+	if req.Method == "" {
+		return "GET"
+	}
+	return req.Method
+}
@@ -0,0 +1,231 @@
+package cliwaretest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/delicb/cliware"
+)
+
+// TestingT is subset of testing.TB used by Mock to report failures.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Mock is cliware.Handler that responds to requests based on registered
+// expectations instead of sending them. Request is matched against
+// expectations in order in which they were registered and first one that
+// matches and is not exhausted is used.
+type Mock struct {
+	t            TestingT
+	mu           sync.Mutex
+	ordered      bool
+	expectations []*Expectation
+	calls        []*http.Request
+	unexpected   []*http.Request
+}
+
+var _ cliware.Handler = (*Mock)(nil)
+
+// NewMock creates Mock that reports failures to provided test.
+func NewMock(t TestingT) *Mock {
+	return &Mock{t: t}
+}
+
+// InOrder makes mock require expectations to be met in order in which they
+// were registered. Request can match expectation only if all previous
+// expectations received their minimal number of calls.
+func (m *Mock) InOrder() *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ordered = true
+	return m
+}
+
+// Expect registers expectation for request that matches all provided
+// matchers. By default, expectation has to be met exactly once and it
+// responds with 200 OK and empty body.
+func (m *Mock) Expect(matchers ...Matcher) *Expectation {
+	e := &Expectation{
+		matchers: matchers,
+		min:      1,
+		max:      1,
+		respond: func(req *http.Request) (*http.Response, error) {
+			return NewResponse(req, http.StatusOK, nil, ""), nil
+		},
+	}
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Handle is implementation of cliware.Handler interface.
+func (m *Mock) Handle(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, req)
+	var matched *Expectation
+	for _, e := range m.expectations {
+		if e.exhausted() || !e.matches(req) {
+			if m.ordered && !e.satisfied() {
+				break
+			}
+			continue
+		}
+		matched = e
+		break
+	}
+	if matched == nil {
+		m.unexpected = append(m.unexpected, req)
+		m.mu.Unlock()
+		return nil, fmt.Errorf("cliwaretest: unexpected request %s", describeRequest(req))
+	}
+	matched.calls++
+	m.mu.Unlock()
+	return matched.respond(req)
+}
+
+// Calls returns all requests received by mock, in order they were received.
+func (m *Mock) Calls() []*http.Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	calls := make([]*http.Request, len(m.calls))
+	copy(calls, m.calls)
+	return calls
+}
+
+// AssertExpectations reports failure for each expectation that did not
+// receive its minimal number of calls and for each request that did not
+// match any expectation. It returns true if there were no failures.
+func (m *Mock) AssertExpectations() bool {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ok := true
+	for _, e := range m.expectations {
+		if !e.satisfied() {
+			m.t.Errorf("cliwaretest: expected request (%s) called %d time(s), but it was called %d time(s)",
+				e, e.min, e.calls)
+			ok = false
+		}
+	}
+	for _, req := range m.unexpected {
+		m.t.Errorf("cliwaretest: unexpected request %s", describeRequest(req))
+		ok = false
+	}
+	return ok
+}
+
+// Expectation is single expected request registered on Mock.
+type Expectation struct {
+	matchers []Matcher
+	respond  func(req *http.Request) (*http.Response, error)
+	min      int
+	max      int
+	calls    int
+}
+
+// Times sets exact number of times request is expected.
+func (e *Expectation) Times(n int) *Expectation {
+	e.min, e.max = n, n
+	return e
+}
+
+// AnyTimes makes expectation optional and allows it to be matched any
+// number of times.
+func (e *Expectation) AnyTimes() *Expectation {
+	e.min, e.max = 0, -1
+	return e
+}
+
+// Respond sets response with provided status code and body.
+func (e *Expectation) Respond(status int, body string) *Expectation {
+	return e.RespondWith(func(req *http.Request) (*http.Response, error) {
+		return NewResponse(req, status, nil, body), nil
+	})
+}
+
+// RespondJSON sets response with provided status code and provided value
+// marshaled to JSON as body.
+func (e *Expectation) RespondJSON(status int, v interface{}) *Expectation {
+	return e.RespondWith(func(req *http.Request) (*http.Response, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{"Content-Type": []string{"application/json"}}
+		return NewResponse(req, status, header, string(data)), nil
+	})
+}
+
+// RespondError makes expectation return provided error instead of response.
+func (e *Expectation) RespondError(err error) *Expectation {
+	return e.RespondWith(func(req *http.Request) (*http.Response, error) {
+		return nil, err
+	})
+}
+
+// RespondWith sets function that creates response for matched request.
+func (e *Expectation) RespondWith(respond func(req *http.Request) (*http.Response, error)) *Expectation {
+	e.respond = respond
+	return e
+}
+
+// String returns description of expectation matchers.
+func (e *Expectation) String() string {
+	descriptions := make([]string, len(e.matchers))
+	for i, matcher := range e.matchers {
+		descriptions[i] = matcher.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func (e *Expectation) matches(req *http.Request) bool {
+	for _, matcher := range e.matchers {
+		if !matcher.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectation) satisfied() bool {
+	return e.calls >= e.min
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.max >= 0 && e.calls >= e.max
+}
+
+// NewResponse creates response for provided request with provided status
+// code, headers and body.
+func NewResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// describeRequest returns short description of request for messages.
+func describeRequest(req *http.Request) string {
+	if req.URL == nil {
+		return requestMethod(req)
+	}
+	return requestMethod(req) + " " + req.URL.String()
+}
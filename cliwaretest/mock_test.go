@@ -0,0 +1,127 @@
+package cliwaretest_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/delicb/cliware"
+	"github.com/delicb/cliware/cliwaretest"
+)
+
+// recorder is TestingT that records reported failures.
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMockResponds(t *testing.T) {
+	mock := cliwaretest.NewMock(t)
+	mock.Expect(cliwaretest.Method("GET"), cliwaretest.Path("/users"), cliwaretest.Query("page", "2")).
+		RespondJSON(http.StatusOK, map[string]string{"name": "cliware"})
+
+	chain := cliware.NewChain(cliware.RequestProcessor(func(req *http.Request) error {
+		req.Header.Set("User-Agent", "cliware")
+		return nil
+	}))
+	req, _ := http.NewRequest("GET", "http://localhost/users?page=2", nil)
+	resp, err := chain.Exec(mock).Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"name":"cliware"}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Wrong response: %s", body)
+	}
+	if resp.Request != req {
+		t.Error("Response does not reference request.")
+	}
+	mock.AssertExpectations()
+}
+
+func TestMockJSONBodyAndHeader(t *testing.T) {
+	mock := cliwaretest.NewMock(t)
+	mock.Expect(cliwaretest.Header("X-Tenant", "a"), cliwaretest.JSONBody(map[string]interface{}{"id": 1, "tags": []string{"x"}})).
+		Respond(http.StatusCreated, "created")
+
+	req, _ := http.NewRequest("POST", "http://localhost/items", strings.NewReader(`{"tags": ["x"], "id": 1}`))
+	req.Header.Set("X-Tenant", "a")
+	resp, err := mock.Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("Wrong status code: %d", resp.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if len(body) == 0 {
+		t.Error("Request body not restored after matching.")
+	}
+	mock.AssertExpectations()
+}
+
+func TestMockUnmetAndUnexpected(t *testing.T) {
+	r := &recorder{}
+	mock := cliwaretest.NewMock(r)
+	mock.Expect(cliwaretest.Path("/expected"))
+	myErr := errors.New("custom error")
+	mock.Expect(cliwaretest.Path("/error")).RespondError(myErr)
+
+	req, _ := http.NewRequest("GET", "http://localhost/error", nil)
+	if _, err := mock.Handle(req); err != myErr {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", myErr, err)
+	}
+	req, _ = http.NewRequest("GET", "http://localhost/other", nil)
+	if _, err := mock.Handle(req); err == nil {
+		t.Error("Expected error for unexpected request.")
+	}
+	if mock.AssertExpectations() {
+		t.Error("Expected assertion to fail.")
+	}
+	if len(r.errors) != 2 {
+		t.Errorf("Expected 2 failures, got: %q", r.errors)
+	}
+	if len(mock.Calls()) != 2 {
+		t.Errorf("Expected 2 recorded calls, got: %d", len(mock.Calls()))
+	}
+}
+
+func TestMockTimes(t *testing.T) {
+	r := &recorder{}
+	mock := cliwaretest.NewMock(r)
+	mock.Expect(cliwaretest.Path("/twice")).Times(2)
+	mock.Expect(cliwaretest.Path("/optional")).AnyTimes()
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://localhost/twice", nil)
+		mock.Handle(req)
+	}
+	mock.AssertExpectations()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "unexpected request GET http://localhost/twice") {
+		t.Errorf("Expected single failure for third call, got: %q", r.errors)
+	}
+}
+
+func TestMockInOrder(t *testing.T) {
+	r := &recorder{}
+	mock := cliwaretest.NewMock(r).InOrder()
+	mock.Expect(cliwaretest.Path("/first"))
+	mock.Expect(cliwaretest.Path("/second"))
+
+	for _, path := range []string{"/second", "/first", "/second"} {
+		req, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+		mock.Handle(req)
+	}
+	mock.AssertExpectations()
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "/second") {
+		t.Errorf("Expected failure for out of order request, got: %q", r.errors)
+	}
+}
@@ -0,0 +1,227 @@
+package cliware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrConcurrencyLimit is returned by ConcurrencyLimiter when request can not
+// be executed because all slots are taken and wait queue is full.
+var ErrConcurrencyLimit = errors.New("cliware: concurrency limit reached")
+
+// ErrQueueTimeout is returned by ConcurrencyLimiter when request waited in
+// queue longer than configured QueueTimeout.
+var ErrQueueTimeout = errors.New("cliware: timeout waiting for concurrency slot")
+
+// ConcurrencyLimiter is Middleware that caps number of concurrent in-flight
+// requests (bulkhead). Limit can be global or applied per key (e.g. host or
+// tenant) if Key function is set.
+//
+// Slot is held until response body is closed, so streaming responses are
+// counted for as long as they are being read. If no response is returned
+// (e.g. error occurred), slot is released immediately.
+type ConcurrencyLimiter struct {
+	// Limit is maximal number of concurrent requests per key. Values lower
+	// than 1 mean 1.
+	Limit int
+
+	// QueueSize is maximal number of requests per key that can wait for a
+	// free slot. If zero, requests are rejected with ErrConcurrencyLimit as
+	// soon as limit is reached. Negative value means unbounded queue.
+	QueueSize int
+
+	// QueueTimeout is maximal time request can spend waiting for a slot.
+	// If zero, request waits until slot is available or request context
+	// is done.
+	QueueTimeout time.Duration
+
+	// Key returns key used to group requests. If nil, all requests share
+	// the same limit.
+	Key func(req *http.Request) string
+
+	// OnQueue, if set, is called every time depth of wait queue for a key
+	// changes.
+	OnQueue func(key string, depth int)
+
+	// OnReject, if set, is called when request is rejected, either because
+	// queue is full, queue timeout expired or request context is done.
+	OnReject func(req *http.Request, err error)
+
+	mu      sync.Mutex
+	buckets map[string]*limiterBucket
+}
+
+// limiterBucket holds state of concurrency limit for single key.
+type limiterBucket struct {
+	slots   chan struct{}
+	waiting int
+	refs    int
+}
+
+// NewConcurrencyLimiter creates ConcurrencyLimiter that allows at most limit
+// concurrent requests without wait queue. Other options can be set on
+// returned instance before it is used.
+func NewConcurrencyLimiter(limit int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		Limit: limit,
+	}
+}
+
+// HostKey is key function for ConcurrencyLimiter (and other middlewares that
+// group requests) that groups requests by target host.
+func HostKey(req *http.Request) string {
+	if req.URL != nil && req.URL.Host != "" {
+		return req.URL.Host
+	}
+	return req.Host
+}
+
+// InFlight returns number of requests currently holding a slot for provided
+// key. For global limiter, use empty string as key.
+func (l *ConcurrencyLimiter) InFlight(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		return 0
+	}
+	return len(b.slots)
+}
+
+// Exec is implementation of Middleware interface.
+func (l *ConcurrencyLimiter) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		var key string
+		if l.Key != nil {
+			key = l.Key(req)
+		}
+		release, err := l.acquire(req, key)
+		if err != nil {
+			if l.OnReject != nil {
+				l.OnReject(req, err)
+			}
+			return nil, err
+		}
+
+		resp, err = next.Handle(req)
+		if resp == nil || resp.Body == nil {
+			release()
+			return resp, err
+		}
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+		return resp, err
+	})
+}
+
+// acquire waits for free slot for provided key and returns function that
+// releases it.
+func (l *ConcurrencyLimiter) acquire(req *http.Request, key string) (func(), error) {
+	b := l.bucket(key)
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-b.slots
+			l.unref(key, b)
+		})
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.QueueSize >= 0 && b.waiting >= l.QueueSize {
+		l.mu.Unlock()
+		l.unref(key, b)
+		return nil, ErrConcurrencyLimit
+	}
+	b.waiting++
+	depth := b.waiting
+	l.mu.Unlock()
+	l.notifyQueue(key, depth)
+
+	var timeout <-chan time.Time
+	if l.QueueTimeout > 0 {
+		timer := time.NewTimer(l.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case b.slots <- struct{}{}:
+	case <-req.Context().Done():
+		err = req.Context().Err()
+	case <-timeout:
+		err = ErrQueueTimeout
+	}
+
+	l.mu.Lock()
+	b.waiting--
+	depth = b.waiting
+	l.mu.Unlock()
+	l.notifyQueue(key, depth)
+
+	if err != nil {
+		l.unref(key, b)
+		return nil, err
+	}
+	return release, nil
+}
+
+// bucket returns bucket for provided key, creating it if needed, and marks
+// it as used.
+func (l *ConcurrencyLimiter) bucket(key string) *limiterBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*limiterBucket)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		limit := l.Limit
+		if limit < 1 {
+			limit = 1
+		}
+		b = &limiterBucket{slots: make(chan struct{}, limit)}
+		l.buckets[key] = b
+	}
+	b.refs++
+	return b
+}
+
+// unref marks bucket as no longer used by one request and removes it when
+// nobody uses it, so per key limiters do not grow indefinitely.
+func (l *ConcurrencyLimiter) unref(key string, b *limiterBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.refs--
+	if b.refs == 0 && l.buckets[key] == b {
+		delete(l.buckets, key)
+	}
+}
+
+func (l *ConcurrencyLimiter) notifyQueue(key string, depth int) {
+	if l.OnQueue != nil {
+		l.OnQueue(key, depth)
+	}
+}
+
+// releaseOnClose is response body wrapper that calls release function
+// when body is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close is implementation of io.Closer interface.
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
@@ -0,0 +1,147 @@
+package cliware_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestConcurrencyLimiterHoldsSlotUntilBodyClosed(t *testing.T) {
+	limiter := c.NewConcurrencyLimiter(1)
+	handler := limiter.Exec(bodyHandler("body"))
+
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if limiter.InFlight("") != 1 {
+		t.Errorf("Expected 1 request in flight, got: %d", limiter.InFlight(""))
+	}
+	_, err = handler.Handle(c.EmptyRequest())
+	if err != c.ErrConcurrencyLimit {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrConcurrencyLimit, err)
+	}
+	resp.Body.Close()
+	if limiter.InFlight("") != 0 {
+		t.Errorf("Expected no requests in flight, got: %d", limiter.InFlight(""))
+	}
+	resp, err = handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	resp.Body.Close()
+}
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	limiter := c.NewConcurrencyLimiter(1)
+	limiter.QueueSize = 1
+	depths := make(chan int, 10)
+	limiter.OnQueue = func(key string, depth int) {
+		depths <- depth
+	}
+	handler := limiter.Exec(bodyHandler("body"))
+
+	first, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	done := make(chan error)
+	go func() {
+		resp, err := handler.Handle(c.EmptyRequest())
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	if depth := <-depths; depth != 1 {
+		t.Errorf("Expected queue depth 1, got: %d", depth)
+	}
+	first.Body.Close()
+	if err := <-done; err != nil {
+		t.Error("Queued request returned error: ", err)
+	}
+	if depth := <-depths; depth != 0 {
+		t.Errorf("Expected queue depth 0, got: %d", depth)
+	}
+}
+
+func TestConcurrencyLimiterQueueTimeout(t *testing.T) {
+	limiter := c.NewConcurrencyLimiter(1)
+	limiter.QueueSize = -1
+	limiter.QueueTimeout = 10 * time.Millisecond
+	var rejected error
+	limiter.OnReject = func(req *http.Request, err error) {
+		rejected = err
+	}
+	handler := limiter.Exec(bodyHandler("body"))
+
+	first, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer first.Body.Close()
+	_, err = handler.Handle(c.EmptyRequest())
+	if err != c.ErrQueueTimeout {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrQueueTimeout, err)
+	}
+	if rejected != c.ErrQueueTimeout {
+		t.Errorf("Expected reject hook with: \"%s\", got: \"%v\"", c.ErrQueueTimeout, rejected)
+	}
+}
+
+func TestConcurrencyLimiterContextCanceled(t *testing.T) {
+	limiter := c.NewConcurrencyLimiter(1)
+	limiter.QueueSize = 1
+	handler := limiter.Exec(bodyHandler("body"))
+
+	first, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer first.Body.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = handler.Handle(c.EmptyRequest().WithContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.Canceled, err)
+	}
+}
+
+func TestConcurrencyLimiterPerKey(t *testing.T) {
+	limiter := c.NewConcurrencyLimiter(1)
+	limiter.Key = c.HostKey
+	handler := limiter.Exec(bodyHandler("body"))
+
+	reqA, _ := http.NewRequest("GET", "http://a.example.com", nil)
+	reqB, _ := http.NewRequest("GET", "http://b.example.com", nil)
+	respA, err := handler.Handle(reqA)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer respA.Body.Close()
+	respB, err := handler.Handle(reqB)
+	if err != nil {
+		t.Fatal("Request for different key rejected: ", err)
+	}
+	respB.Body.Close()
+	if limiter.InFlight("b.example.com") != 0 {
+		t.Error("Slot for closed response not released.")
+	}
+}
+
+func TestConcurrencyLimiterInvalidLimit(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		handler := c.NewConcurrencyLimiter(limit).Exec(bodyHandler("body"))
+		resp, err := handler.Handle(c.EmptyRequest())
+		if err != nil {
+			t.Fatalf("Handle with limit %d returned error: %v", limit, err)
+		}
+		if _, err := handler.Handle(c.EmptyRequest()); err != c.ErrConcurrencyLimit {
+			t.Errorf("Expected limit %d to allow single request, got: %v", limit, err)
+		}
+		resp.Body.Close()
+	}
+}
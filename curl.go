@@ -0,0 +1,230 @@
+package cliware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// defaultCurlInlineBody is default maximal size of body included directly
+// in curl command line.
+const defaultCurlInlineBody = 4 << 10
+
+// redactedValue replaces secrets in generated commands.
+const redactedValue = "REDACTED"
+
+// CurlDumper is Middleware that converts each request passing through it
+// into equivalent curl command line and sends it to Sink. It is intended for
+// debugging and reproducing requests by hand.
+//
+// Request body is included inline if it is small and textual, otherwise it
+// is written to temporary file that is referenced from the command. Body
+// that can not be reopened is buffered in memory (see RewindableBody).
+// Temporary files are owned by dumper and are kept until Cleanup is called,
+// so commands can be run while debugging. Since they might contain secrets,
+// Cleanup should be called when commands are not needed anymore.
+type CurlDumper struct {
+	// Sink receives generated commands.
+	Sink func(req *http.Request, command string)
+
+	// Redact enables hiding of secrets: values of Authorization, Cookie and
+	// Proxy-Authorization headers, headers listed in RedactHeaders and
+	// basic authentication password.
+	Redact bool
+
+	// RedactHeaders are additional headers whose values are hidden when
+	// Redact is set, e.g. X-Api-Key.
+	RedactHeaders []string
+
+	// MaxInlineBody is maximal body size that is included directly in
+	// command. Larger bodies are written to temporary file.
+	MaxInlineBody int64
+
+	// TempDir is directory for body files. If empty, default directory for
+	// temporary files is used.
+	TempDir string
+
+	mu        sync.Mutex
+	tempFiles []string
+}
+
+// NewCurlDumper creates CurlDumper that sends generated commands to provided
+// sink.
+func NewCurlDumper(sink func(req *http.Request, command string)) *CurlDumper {
+	return &CurlDumper{
+		Sink:          sink,
+		MaxInlineBody: defaultCurlInlineBody,
+	}
+}
+
+// Cleanup removes all temporary body files created so far. Commands that
+// reference them can not be run after that.
+func (d *CurlDumper) Cleanup() error {
+	d.mu.Lock()
+	files := d.tempFiles
+	d.tempFiles = nil
+	d.mu.Unlock()
+	var firstErr error
+	for _, name := range files {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Exec is implementation of Middleware interface. If Sink is nil, requests
+// are passed to next handler untouched.
+func (d *CurlDumper) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		if d.Sink == nil {
+			return next.Handle(req)
+		}
+		if err := RewindableBody(req); err != nil {
+			return nil, err
+		}
+		command, err := d.Command(req)
+		if err != nil {
+			command = "# cliware: unable to generate curl command: " + err.Error()
+		}
+		d.Sink(req, command)
+		return next.Handle(req)
+	})
+}
+
+// Command returns curl command line equivalent to provided request. Request
+// body is read using GetBody, so request has to have it set if it has body.
+func (d *CurlDumper) Command(req *http.Request) (string, error) {
+	args := []string{"curl"}
+	method := req.Method
+	if method == "" {
+		method = "GET"
+	}
+	if method != "GET" {
+		args = append(args, "-X", shellQuote(method))
+	}
+
+	header := cloneHeader(req.Header)
+	if header == nil {
+		header = make(http.Header)
+	}
+	if username, password, ok := req.BasicAuth(); ok {
+		if d.Redact {
+			password = redactedValue
+		}
+		args = append(args, "-u", shellQuote(username+":"+password))
+		header.Del("Authorization")
+	}
+	if strings.Contains(header.Get("Accept-Encoding"), "gzip") {
+		args = append(args, "--compressed")
+		header.Del("Accept-Encoding")
+	}
+	if req.Host != "" && (req.URL == nil || req.Host != req.URL.Host) {
+		header.Set("Host", req.Host)
+	}
+
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if d.Redact && d.isSecret(k) {
+				v = redactedValue
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+
+	bodyArgs, err := d.body(req)
+	if err != nil {
+		return "", err
+	}
+	args = append(args, bodyArgs...)
+
+	if req.URL != nil {
+		u := req.URL
+		if _, ok := u.User.Password(); ok && d.Redact {
+			redacted := *u
+			redacted.User = url.UserPassword(u.User.Username(), redactedValue)
+			u = &redacted
+		}
+		args = append(args, shellQuote(u.String()))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// body returns curl arguments for request body.
+func (d *CurlDumper) body(req *http.Request) ([]string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, ErrBodyNotRewindable
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(body)
+	_ = body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	// curl treats --data-binary value starting with @ as file name, so such
+	// bodies always go to file
+	inline := int64(len(data)) <= d.MaxInlineBody && data[0] != '@'
+	if inline && utf8.Valid(data) && !bytes.ContainsRune(data, 0) {
+		return []string{"--data-binary", shellQuote(string(data))}, nil
+	}
+
+	f, err := ioutil.TempFile(d.TempDir, "cliware-curl-")
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.tempFiles = append(d.tempFiles, f.Name())
+	d.mu.Unlock()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string{"--data-binary", shellQuote("@" + f.Name())}, nil
+}
+
+// isSecret checks if header holds secret that should be redacted.
+func (d *CurlDumper) isSecret(header string) bool {
+	switch http.CanonicalHeaderKey(header) {
+	case "Authorization", "Proxy-Authorization", "Cookie":
+		return true
+	}
+	for _, h := range d.RedactHeaders {
+		if http.CanonicalHeaderKey(h) == http.CanonicalHeaderKey(header) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes provided string for POSIX shell, if needed.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,188 @@
+package cliware
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deduplicator is Middleware that collapses concurrent identical requests
+// into single call to next Handler (also known as singleflight). Requests
+// are identical if they have same method, URL, credentials (Authorization
+// and Cookie headers) and values of configured headers, so responses are
+// never shared between callers with different credentials.
+//
+// Response body of shared call is read into memory and each waiter gets its
+// own copy of response with independent body. Waiter whose context is done
+// stops waiting and gets context error, but shared call continues as long as
+// there is at least one waiter left.
+type Deduplicator struct {
+	// Headers are names of request headers that are part of deduplication
+	// key in addition to Authorization and Cookie, e.g. Accept.
+	Headers []string
+
+	// ShouldDeduplicate decides if request can be deduplicated. If nil, only
+	// GET and HEAD requests are deduplicated.
+	ShouldDeduplicate func(req *http.Request) bool
+
+	mu    sync.Mutex
+	calls map[string]*dedupCall
+}
+
+// dedupCall is single shared call to next Handler.
+type dedupCall struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	resp *http.Response
+	body []byte
+	err  error
+}
+
+// NewDeduplicator creates Deduplicator that uses provided headers (in
+// addition to method, URL and credentials) as part of deduplication key.
+func NewDeduplicator(headers ...string) *Deduplicator {
+	return &Deduplicator{
+		Headers: headers,
+	}
+}
+
+// Waiting returns number of callers waiting for shared call for requests
+// identical to provided one.
+func (d *Deduplicator) Waiting(req *http.Request) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	call, ok := d.calls[d.key(req)]
+	if !ok {
+		return 0
+	}
+	return call.waiters
+}
+
+// Exec is implementation of Middleware interface.
+func (d *Deduplicator) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		if !d.shouldDeduplicate(req) {
+			return next.Handle(req)
+		}
+		key := d.key(req)
+
+		d.mu.Lock()
+		if d.calls == nil {
+			d.calls = make(map[string]*dedupCall)
+		}
+		call, ok := d.calls[key]
+		if !ok {
+			ctx, cancel := context.WithCancel(detachedContext{req.Context()})
+			shared, err := CloneRequest(ctx, req)
+			if err != nil {
+				d.mu.Unlock()
+				cancel()
+				return next.Handle(req)
+			}
+			call = &dedupCall{done: make(chan struct{}), cancel: cancel}
+			d.calls[key] = call
+			go d.run(key, call, next, shared)
+		}
+		call.waiters++
+		d.mu.Unlock()
+
+		select {
+		case <-call.done:
+			return call.response(req)
+		case <-req.Context().Done():
+			d.mu.Lock()
+			call.waiters--
+			if call.waiters == 0 {
+				// nobody is interested in result anymore, new requests
+				// should not join canceled call
+				call.cancel()
+				if d.calls[key] == call {
+					delete(d.calls, key)
+				}
+			}
+			d.mu.Unlock()
+			return nil, req.Context().Err()
+		}
+	})
+}
+
+// run executes shared call and notifies all waiters.
+func (d *Deduplicator) run(key string, call *dedupCall, next Handler, req *http.Request) {
+	defer call.cancel()
+	resp, err := next.Handle(req)
+	if err == nil && resp != nil && resp.Body != nil {
+		call.body, err = ioutil.ReadAll(resp.Body)
+		if closeErr := resp.Body.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	call.resp, call.err = resp, err
+
+	d.mu.Lock()
+	if d.calls[key] == call {
+		delete(d.calls, key)
+	}
+	d.mu.Unlock()
+	close(call.done)
+}
+
+// response returns independent copy of shared response for provided request.
+func (call *dedupCall) response(req *http.Request) (*http.Response, error) {
+	if call.resp == nil {
+		return nil, call.err
+	}
+	resp := *call.resp
+	resp.Header = cloneHeader(call.resp.Header)
+	resp.Trailer = cloneHeader(call.resp.Trailer)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(call.body))
+	resp.Request = req
+	return &resp, call.err
+}
+
+func (d *Deduplicator) shouldDeduplicate(req *http.Request) bool {
+	if d.ShouldDeduplicate != nil {
+		return d.ShouldDeduplicate(req)
+	}
+	return req.Method == "" || req.Method == "GET" || req.Method == "HEAD"
+}
+
+// credentialHeaders are headers that are always part of deduplication key.
+var credentialHeaders = []string{"Authorization", "Cookie"}
+
+// key returns deduplication key for request.
+func (d *Deduplicator) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte(' ')
+	if req.URL != nil {
+		b.WriteString(req.URL.String())
+	}
+	for _, h := range append(credentialHeaders, d.Headers...) {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header[http.CanonicalHeaderKey(h)], ","))
+	}
+	return b.String()
+}
+
+// detachedContext is context that carries values of its parent, but is
+// never canceled and has no deadline. It is used for work shared between
+// multiple callers that should not be canceled when one of them gives up.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (deadline time.Time, ok bool) { return }
+func (detachedContext) Done() <-chan struct{}                   { return nil }
+func (detachedContext) Err() error                              { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
@@ -0,0 +1,153 @@
+package cliware_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	c "github.com/delicb/cliware"
+)
+
+// blockingHandler returns handler that waits for release channel to be
+// closed before responding with provided body.
+func blockingHandler(calls *int32, release chan struct{}, body string) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(calls, 1)
+		<-release
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Test": []string{"value"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestDeduplicatorCollapsesRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	d := c.NewDeduplicator()
+	handler := d.Exec(blockingHandler(&calls, release, "shared"))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "http://localhost/config", nil)
+			resp, err := handler.Handle(req)
+			if err != nil {
+				t.Error("Handle returned error: ", err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.Request != req {
+				t.Error("Response does not reference waiter's request.")
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			bodies[i] = string(body)
+		}(i)
+	}
+	probe, _ := http.NewRequest("GET", "http://localhost/config", nil)
+	for d.Waiting(probe) < len(bodies) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected single call, got: %d", calls)
+	}
+	for _, body := range bodies {
+		if body != "shared" {
+			t.Errorf("Waiter got wrong body: %q", body)
+		}
+	}
+}
+
+func TestDeduplicatorKeyHeaders(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	d := c.NewDeduplicator("Accept")
+	handler := d.Exec(blockingHandler(&calls, release, "body"))
+
+	headers := []struct{ name, value string }{
+		{"Accept", "text/plain"},
+		{"Accept", "application/json"},
+		{"Authorization", "a"},
+		{"Cookie", "session=b"},
+	}
+	var wg sync.WaitGroup
+	for _, h := range headers {
+		req, _ := http.NewRequest("GET", "http://localhost/config", nil)
+		req.Header.Set(h.name, h.value)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := handler.Handle(req); err != nil {
+				t.Error("Handle returned error: ", err)
+			}
+		}()
+		// wait for request to start its own shared call
+		for d.Waiting(req) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	close(release)
+	wg.Wait()
+
+	if atomic.LoadInt32(&calls) != int32(len(headers)) {
+		t.Errorf("Expected %d calls for different headers, got: %d", len(headers), calls)
+	}
+}
+
+func TestDeduplicatorWaiterCanceled(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	handler := c.NewDeduplicator().Exec(blockingHandler(&calls, release, "body"))
+
+	first := make(chan error)
+	go func() {
+		req, _ := http.NewRequest("GET", "http://localhost/config", nil)
+		_, err := handler.Handle(req)
+		first <- err
+	}()
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequest("GET", "http://localhost/config", nil)
+	if _, err := handler.Handle(req.WithContext(ctx)); err != context.Canceled {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.Canceled, err)
+	}
+	close(release)
+	if err := <-first; err != nil {
+		t.Error("Shared request failed after other waiter detached: ", err)
+	}
+}
+
+func TestDeduplicatorSkipsPost(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	close(release)
+	handler := c.NewDeduplicator().Exec(blockingHandler(&calls, release, "body"))
+	req, _ := http.NewRequest("POST", "http://localhost/config", nil)
+	resp, err := handler.Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if resp.Request != req {
+		t.Error("POST request was not passed directly to next handler.")
+	}
+}
@@ -0,0 +1,183 @@
+package cliware
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// MiddlewareInfo describes single middleware in flattened chain.
+type MiddlewareInfo struct {
+	// Index is position of middleware in execution order.
+	Index int
+	// Type is Go type of middleware, e.g. "cliware.RequestProcessor". For
+	// named middlewares, type of wrapped middleware is used.
+	Type string
+	// Name is name given to middleware with Named, empty for unnamed ones.
+	Name string
+	// Chain is chain middleware was added to.
+	Chain *Chain
+	// Level is nesting level of Chain, 0 for root of hierarchy. Child
+	// chains and chains used as middlewares are one level deeper than
+	// chain that contains them.
+	Level int
+	// Middleware is described middleware itself.
+	Middleware Middleware
+}
+
+// SetName sets name of chain used when chain is described. It returns chain
+// itself, so it can be used right after chain creation.
+func (c *Chain) SetName(name string) *Chain {
+	c.name = name
+	return c
+}
+
+// Name returns name of chain, empty if it was not set.
+func (c *Chain) Name() string {
+	return c.name
+}
+
+// ParentChain returns parent of this chain, or nil if chain has no parent.
+func (c *Chain) ParentChain() *Chain {
+	parent, _ := c.parent.(*Chain)
+	return parent
+}
+
+// Describe returns all middlewares that are executed by chain, including
+// ones from parent chains and chains used as middlewares, in order in which
+// they are executed.
+func (c *Chain) Describe() []MiddlewareInfo {
+	var infos []MiddlewareInfo
+	level := 0
+	visitChain(c, chainVisitor{
+		enter: func(*Chain) { level++ },
+		leave: func(*Chain) { level-- },
+		middleware: func(chain *Chain, m Middleware) {
+			info := describeMiddleware(m)
+			info.Index = len(infos)
+			info.Chain = chain
+			info.Level = level - 1
+			infos = append(infos, info)
+		},
+	})
+	return infos
+}
+
+// Tree returns description of chain as indented text tree. Each chain is
+// followed by its middlewares in execution order, with chains they lead to
+// indented below.
+func (c *Chain) Tree() string {
+	var buf bytes.Buffer
+	indent := ""
+	index := 0
+	visitChain(c, chainVisitor{
+		enter: func(chain *Chain) {
+			fmt.Fprintf(&buf, "%schain %s\n", indent, chainLabel(chain))
+			indent += "  "
+		},
+		leave: func(*Chain) { indent = indent[2:] },
+		middleware: func(chain *Chain, m Middleware) {
+			fmt.Fprintf(&buf, "%s%d. %s\n", indent, index, middlewareLabel(describeMiddleware(m)))
+			index++
+		},
+	})
+	return buf.String()
+}
+
+// DOT returns description of chain in Graphviz DOT format. Chains are drawn
+// as clusters and edges follow execution order, ending in final handler.
+func (c *Chain) DOT() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph chain {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	indent := "\t"
+	clusters := 0
+	index := 0
+	visitChain(c, chainVisitor{
+		enter: func(chain *Chain) {
+			fmt.Fprintf(&buf, "%ssubgraph cluster_%d {\n", indent, clusters)
+			indent += "\t"
+			fmt.Fprintf(&buf, "%slabel=%s;\n", indent, strconv.Quote("chain "+chainLabel(chain)))
+			clusters++
+		},
+		leave: func(*Chain) {
+			indent = indent[1:]
+			fmt.Fprintf(&buf, "%s}\n", indent)
+		},
+		middleware: func(chain *Chain, m Middleware) {
+			label := middlewareLabel(describeMiddleware(m))
+			fmt.Fprintf(&buf, "%sm%d [label=%s];\n", indent, index, strconv.Quote(label))
+			index++
+		},
+	})
+	buf.WriteString("\thandler [label=\"handler\", shape=ellipse];\n")
+	for i := 0; i < index; i++ {
+		next := "handler"
+		if i+1 < index {
+			next = "m" + strconv.Itoa(i+1)
+		}
+		fmt.Fprintf(&buf, "\tm%d -> %s;\n", i, next)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// chainVisitor receives callbacks while chain hierarchy is walked.
+type chainVisitor struct {
+	enter      func(c *Chain)
+	leave      func(c *Chain)
+	middleware func(c *Chain, m Middleware)
+}
+
+// visitChain walks chain, its parents and chains used as middlewares in
+// execution order. Parents are executed first, so each child chain is
+// visited inside of its parent, after parent middlewares.
+func visitChain(c *Chain, v chainVisitor) {
+	var lineage []*Chain
+	for chain := c; chain != nil; chain = chain.ParentChain() {
+		lineage = append([]*Chain{chain}, lineage...)
+	}
+	visitLineage(lineage, v)
+}
+
+// visitLineage visits first chain in lineage and, inside of it, the rest.
+func visitLineage(lineage []*Chain, v chainVisitor) {
+	c := lineage[0]
+	v.enter(c)
+	for _, m := range c.middlewares {
+		if nested, ok := m.(*Chain); ok {
+			visitChain(nested, v)
+			continue
+		}
+		v.middleware(c, m)
+	}
+	if len(lineage) > 1 {
+		visitLineage(lineage[1:], v)
+	}
+	v.leave(c)
+}
+
+// describeMiddleware returns type and name of middleware.
+func describeMiddleware(m Middleware) MiddlewareInfo {
+	info := MiddlewareInfo{Middleware: m, Type: fmt.Sprintf("%T", m)}
+	if named, ok := m.(*NamedMiddleware); ok {
+		info.Name = named.Name
+		info.Type = fmt.Sprintf("%T", named.Middleware)
+	}
+	return info
+}
+
+// chainLabel returns name of chain for descriptions.
+func chainLabel(c *Chain) string {
+	if c.name == "" {
+		return "(unnamed)"
+	}
+	return strconv.Quote(c.name)
+}
+
+// middlewareLabel returns single line description of middleware.
+func middlewareLabel(info MiddlewareInfo) string {
+	if info.Name == "" {
+		return info.Type
+	}
+	return info.Name + " (" + info.Type + ")"
+}
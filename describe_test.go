@@ -0,0 +1,83 @@
+package cliware_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func describedChain() *c.Chain {
+	noop := func(req *http.Request) error { return nil }
+	base := c.NewChain(c.Named("auth", c.RequestProcessor(noop))).SetName("base")
+	nested := c.NewChain(c.RequestProcessor(noop)).SetName("nested")
+	return base.ChildChain(nested, c.Named("retry", c.RequestProcessor(noop))).SetName("api")
+}
+
+func TestDescribe(t *testing.T) {
+	infos := describedChain().Describe()
+	expected := []struct {
+		name, chain string
+		level       int
+	}{
+		{"auth", "base", 0},
+		{"", "nested", 2},
+		{"retry", "api", 1},
+	}
+	if len(infos) != len(expected) {
+		t.Fatalf("Expected %d middlewares, got %d", len(expected), len(infos))
+	}
+	for i, e := range expected {
+		info := infos[i]
+		if info.Index != i || info.Name != e.name || info.Chain.Name() != e.chain || info.Level != e.level {
+			t.Errorf("Unexpected middleware %d: %+v", i, info)
+		}
+		if info.Type != "cliware.RequestProcessor" {
+			t.Errorf("Unexpected type of middleware %d: %s", i, info.Type)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	expected := strings.Join([]string{
+		`chain "base"`,
+		`  0. auth (cliware.RequestProcessor)`,
+		`  chain "api"`,
+		`    chain "nested"`,
+		`      1. cliware.RequestProcessor`,
+		`    2. retry (cliware.RequestProcessor)`,
+		``,
+	}, "\n")
+	if tree := describedChain().Tree(); tree != expected {
+		t.Errorf("Unexpected tree:\n%s\nexpected:\n%s", tree, expected)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	dot := describedChain().DOT()
+	for _, s := range []string{
+		"digraph chain {",
+		`label="chain \"nested\"";`,
+		`m0 [label="auth (cliware.RequestProcessor)"];`,
+		"m0 -> m1;",
+		"m2 -> handler;",
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", s, dot)
+		}
+	}
+	if strings.Count(dot, "subgraph") != 3 {
+		t.Errorf("Expected 3 clusters, got:\n%s", dot)
+	}
+}
+
+func TestParentChain(t *testing.T) {
+	chain := c.NewChain()
+	if chain.ParentChain() != nil {
+		t.Error("Expected no parent chain.")
+	}
+	if chain.ChildChain().ParentChain() != chain {
+		t.Error("Parent chain not set properly.")
+	}
+}
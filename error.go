@@ -0,0 +1,132 @@
+package cliware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Phase tells in which part of request processing error occurred.
+type Phase int
+
+const (
+	// RequestPhase is processing of request, before it is sent.
+	RequestPhase Phase = iota
+	// ResponsePhase is processing of response, after it is received.
+	ResponsePhase
+	// TransportPhase is sending of request by final handler.
+	TransportPhase
+)
+
+// String returns name of phase.
+func (p Phase) String() string {
+	switch p {
+	case RequestPhase:
+		return "request"
+	case ResponsePhase:
+		return "response"
+	case TransportPhase:
+		return "transport"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// ChainError is error that records where in chain original error occurred.
+// Chains with tracing enabled (see SetTraceObserver) wrap errors returned by
+// RequestProcessor and ResponseProcessor middlewares and by final handler
+// into ChainError. Errors of final handler are attributed to outermost
+// traced chain request passes through.
+type ChainError struct {
+	// Chain is chain in which error occurred.
+	Chain *Chain
+	// Index is position of middleware in Chain, or -1 for errors returned
+	// by final handler.
+	Index int
+	// Name is name given to middleware with Named, empty for unnamed ones.
+	Name string
+	// Phase is part of request processing in which error occurred.
+	Phase Phase
+	// Err is original error.
+	Err error
+}
+
+// Error is implementation of error interface.
+func (e *ChainError) Error() string {
+	location := "final handler"
+	if e.Index >= 0 {
+		location = fmt.Sprintf("middleware %d", e.Index)
+		if e.Name != "" {
+			location += " (" + e.Name + ")"
+		}
+	}
+	return fmt.Sprintf("cliware: %s error in %s of chain %s: %v",
+		e.Phase, location, chainLabel(e.Chain), e.Err)
+}
+
+// Unwrap returns original error.
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// currentMiddlewareKey is context key under which traced middleware that
+// is currently running is stored.
+type currentMiddlewareKey struct{}
+
+// withCurrentMiddleware returns context that marks provided traced
+// middleware as currently running.
+func withCurrentMiddleware(ctx context.Context, t *tracedMiddleware) context.Context {
+	return context.WithValue(ctx, currentMiddlewareKey{}, t)
+}
+
+// wrapProcessorError wraps error returned by processor middleware into
+// ChainError, if middleware runs in traced chain.
+func wrapProcessorError(req *http.Request, phase Phase, err error) error {
+	if req == nil {
+		return err
+	}
+	t, ok := req.Context().Value(currentMiddlewareKey{}).(*tracedMiddleware)
+	if !ok {
+		return err
+	}
+	return &ChainError{
+		Chain: t.chain,
+		Index: t.index,
+		Name:  describeMiddleware(t.middleware).Name,
+		Phase: phase,
+		Err:   err,
+	}
+}
+
+// transportOwnerKey is context key under which transportOwner of request
+// is stored.
+type transportOwnerKey struct{}
+
+// transportOwner attributes errors of final handler in traced chain. Only
+// outermost traced chain request passes through owns it, so chains used as
+// middlewares do not treat rest of outer chain as final handler.
+type transportOwner struct {
+	chain *Chain
+}
+
+// claim marks owner as owner of request, unless request already has one.
+func (o *transportOwner) claim(req *http.Request) *http.Request {
+	if req == nil || req.Context().Value(transportOwnerKey{}) != nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), transportOwnerKey{}, o))
+}
+
+// wrap returns handler that wraps errors returned by provided final handler
+// into ChainError, for requests owned by owner.
+func (o *transportOwner) wrap(handler Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := handler.Handle(req)
+		if err == nil || req == nil || req.Context().Value(transportOwnerKey{}) != o {
+			return resp, err
+		}
+		if _, ok := err.(*ChainError); ok {
+			return resp, err
+		}
+		return resp, &ChainError{Chain: o.chain, Index: -1, Phase: TransportPhase, Err: err}
+	})
+}
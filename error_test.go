@@ -0,0 +1,128 @@
+package cliware_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+var errTest = errors.New("test error")
+
+func tracedChain(m ...c.Middleware) *c.Chain {
+	return c.NewChain(m...).SetName("traced").SetTraceObserver(c.TraceObserverFunc(func(*c.Hop) {}))
+}
+
+func TestChainErrorRequestPhase(t *testing.T) {
+	chain := tracedChain(
+		c.RequestProcessor(func(req *http.Request) error { return nil }),
+		c.Named("auth", c.RequestProcessor(func(req *http.Request) error { return errTest })),
+	)
+	handler, _ := createHandler()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := chain.Exec(handler).Handle(req)
+
+	var chainErr *c.ChainError
+	if !errors.As(err, &chainErr) {
+		t.Fatalf("Expected *ChainError, got: %v", err)
+	}
+	if chainErr.Chain != chain || chainErr.Index != 1 || chainErr.Name != "auth" || chainErr.Phase != c.RequestPhase {
+		t.Errorf("Unexpected chain error: %+v", chainErr)
+	}
+	if !errors.Is(err, errTest) {
+		t.Error("Chain error does not wrap original error.")
+	}
+	expected := `cliware: request error in middleware 1 (auth) of chain "traced": test error`
+	if err.Error() != expected {
+		t.Errorf("Unexpected message: %s", err)
+	}
+}
+
+func TestChainErrorResponsePhase(t *testing.T) {
+	chain := tracedChain(c.ResponseProcessor(func(resp *http.Response, err error) error { return errTest }))
+	handler, _ := createHandler()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := chain.Exec(handler).Handle(req)
+
+	var chainErr *c.ChainError
+	if !errors.As(err, &chainErr) || chainErr.Index != 0 || chainErr.Phase != c.ResponsePhase {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestChainErrorTransportPhase(t *testing.T) {
+	var seen error
+	chain := tracedChain(c.ResponseProcessor(func(resp *http.Response, err error) error {
+		seen = err
+		return err
+	}))
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := chain.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errTest
+	})).Handle(req)
+
+	var chainErr *c.ChainError
+	if !errors.As(err, &chainErr) || chainErr.Index != -1 || chainErr.Phase != c.TransportPhase {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if seen != err {
+		t.Error("Returning received error should not wrap it again.")
+	}
+	if err.Error() != `cliware: transport error in final handler of chain "traced": test error` {
+		t.Errorf("Unexpected message: %s", err)
+	}
+}
+
+func TestNoChainErrorWithoutTracing(t *testing.T) {
+	chain := c.NewChain(c.RequestProcessor(func(req *http.Request) error { return errTest }))
+	handler, _ := createHandler()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := chain.Exec(handler).Handle(req); err != errTest {
+		t.Errorf("Expected original error without tracing, got: %v", err)
+	}
+}
+
+func TestChainErrorChildMiddleware(t *testing.T) {
+	parent := tracedChain()
+	child := parent.ChildChain(c.MiddlewareFunc(func(next c.Handler) c.Handler {
+		return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errTest
+		})
+	}))
+	handler := child.Exec(c.HandlerFunc(nilHandler))
+
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	for _, r := range []*http.Request{req, req.WithContext(c.WithoutMiddlewares(req.Context(), "unknown"))} {
+		if _, err := handler.Handle(r); err != errTest {
+			t.Errorf("Expected plain middleware error, got: %v", err)
+		}
+	}
+}
+
+func TestChainErrorTransportFromChild(t *testing.T) {
+	parent := tracedChain()
+	child := parent.ChildChain()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := child.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errTest
+	})).Handle(req)
+
+	var chainErr *c.ChainError
+	if !errors.As(err, &chainErr) || chainErr.Chain != child || chainErr.Phase != c.TransportPhase {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestChainErrorNestedChain(t *testing.T) {
+	nested := tracedChain()
+	outer := c.NewChain(nested, c.MiddlewareFunc(func(next c.Handler) c.Handler {
+		return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, errTest
+		})
+	})).SetTraceObserver(c.TraceObserverFunc(func(*c.Hop) {}))
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := outer.Exec(c.HandlerFunc(nilHandler)).Handle(req); err != errTest {
+		t.Errorf("Expected plain middleware error, got: %v", err)
+	}
+}
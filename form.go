@@ -0,0 +1,188 @@
+package cliware
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FormBody returns RequestProcessor that sets provided values as
+// application/x-www-form-urlencoded request body.
+func FormBody(values url.Values) RequestProcessor {
+	return RequestProcessor(func(req *http.Request) error {
+		encoded := values.Encode()
+		req.Body = ioutil.NopCloser(strings.NewReader(encoded))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(encoded)), nil
+		}
+		req.ContentLength = int64(len(encoded))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return nil
+	})
+}
+
+// MultipartPart is single part of multipart/form-data body. Use FormField,
+// FormFile, FormFileFromPath or FormPart to create one.
+type MultipartPart struct {
+	header textproto.MIMEHeader
+	open   func() (io.ReadCloser, error)
+	reader io.Reader
+}
+
+// FormField creates multipart part with simple form value.
+func FormField(name, value string) MultipartPart {
+	return FormPart(fieldHeader(name, ""), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(value)), nil
+	})
+}
+
+// FormFile creates multipart part with file content read from provided
+// reader. Since reader can be read only once, request with this part can
+// not be sent more than once (e.g. retried).
+func FormFile(field, filename string, r io.Reader) MultipartPart {
+	header := fieldHeader(field, filename)
+	header.Set("Content-Type", "application/octet-stream")
+	return MultipartPart{header: header, reader: r}
+}
+
+// FormFileFromPath creates multipart part with content of file on provided
+// path. File is opened only when body is sent and it is opened again if
+// request is sent more than once. Content type is guessed from file
+// extension.
+func FormFileFromPath(field, path string) MultipartPart {
+	header := fieldHeader(field, filepath.Base(path))
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	return FormPart(header, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// FormPart creates multipart part with custom headers. Provided open
+// function is called each time part content needs to be written.
+func FormPart(header textproto.MIMEHeader, open func() (io.ReadCloser, error)) MultipartPart {
+	return MultipartPart{header: header, open: open}
+}
+
+// MultipartBody returns RequestProcessor that sets multipart/form-data
+// request body with provided parts.
+//
+// Body is streamed through io.Pipe, so large files are not buffered in
+// memory. Because of that, content length is not known in advance. Request
+// GetBody is set only if all parts can be reopened (i.e. none of them was
+// created by FormFile), so request can be safely sent again.
+func MultipartBody(parts ...MultipartPart) RequestProcessor {
+	return RequestProcessor(func(req *http.Request) error {
+		boundary := multipart.NewWriter(nil).Boundary()
+		reopenable := true
+		for _, p := range parts {
+			if p.open == nil {
+				reopenable = false
+			}
+		}
+
+		req.Body = newMultipartReader(boundary, parts)
+		if reopenable {
+			req.GetBody = func() (io.ReadCloser, error) {
+				return newMultipartReader(boundary, parts), nil
+			}
+		} else {
+			req.GetBody = nil
+		}
+		req.ContentLength = -1
+		req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+		return nil
+	})
+}
+
+// multipartReader is body that writes multipart content to pipe once it
+// is first read, so nothing is started for bodies that are never sent.
+type multipartReader struct {
+	boundary string
+	parts    []MultipartPart
+	once     sync.Once
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+}
+
+func newMultipartReader(boundary string, parts []MultipartPart) *multipartReader {
+	pr, pw := io.Pipe()
+	return &multipartReader{boundary: boundary, parts: parts, pr: pr, pw: pw}
+}
+
+// Read is implementation of io.Reader interface.
+func (m *multipartReader) Read(p []byte) (int, error) {
+	m.once.Do(func() {
+		go func() {
+			m.pw.CloseWithError(m.write())
+		}()
+	})
+	return m.pr.Read(p)
+}
+
+// Close is implementation of io.Closer interface.
+func (m *multipartReader) Close() error {
+	return m.pr.Close()
+}
+
+// write writes all parts to pipe.
+func (m *multipartReader) write() error {
+	w := multipart.NewWriter(m.pw)
+	if err := w.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		partWriter, err := w.CreatePart(p.header)
+		if err != nil {
+			return err
+		}
+		if p.reader != nil {
+			if _, err := io.Copy(partWriter, p.reader); err != nil {
+				return err
+			}
+			continue
+		}
+		content, err := p.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(partWriter, content)
+		closeErr := content.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+	return w.Close()
+}
+
+// fieldHeader returns part header with Content-Disposition for form field.
+func fieldHeader(name, filename string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(name))
+	if filename != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(filename))
+	}
+	header.Set("Content-Disposition", disposition)
+	return header
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
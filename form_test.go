@@ -0,0 +1,130 @@
+package cliware_test
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestFormBody(t *testing.T) {
+	req := c.EmptyRequest()
+	values := url.Values{"name": []string{"cliware"}, "tag": []string{"a b", "c&d"}}
+	if err := c.FormBody(values)(req); err != nil {
+		t.Fatal("Processor returned error: ", err)
+	}
+	if req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Wrong content type: %s", req.Header.Get("Content-Type"))
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != "name=cliware&tag=a+b&tag=c%26d" {
+		t.Errorf("Wrong body: %s", body)
+	}
+	if req.ContentLength != int64(len(body)) || req.GetBody == nil {
+		t.Error("Content length or GetBody not set.")
+	}
+}
+
+// readMultipart parses multipart body of provided request and returns
+// content of each part by form name.
+func readMultipart(t *testing.T, req *http.Request, body io.Reader) map[string]*multipart.Part {
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal("Wrong content type: ", err)
+	}
+	parts := make(map[string]*multipart.Part)
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal("Reading multipart body failed: ", err)
+		}
+		content, _ := ioutil.ReadAll(part)
+		part.Header.Set("X-Content", string(content))
+		parts[part.FormName()] = part
+	}
+}
+
+func TestMultipartBody(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cliware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data.txt")
+	if err := ioutil.WriteFile(path, []byte("file content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	custom := textproto.MIMEHeader{}
+	custom.Set("Content-Disposition", `form-data; name="meta"`)
+	custom.Set("Content-Type", "application/json")
+
+	req := c.EmptyRequest()
+	processor := c.MultipartBody(
+		c.FormField("name", "value"),
+		c.FormFileFromPath("upload", path),
+		c.FormPart(custom, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(`{}`)), nil
+		}),
+	)
+	if err := processor(req); err != nil {
+		t.Fatal("Processor returned error: ", err)
+	}
+	if req.GetBody == nil {
+		t.Fatal("GetBody not set for reopenable parts.")
+	}
+	for _, body := range []func() (io.ReadCloser, error){
+		func() (io.ReadCloser, error) { return req.Body, nil },
+		req.GetBody,
+	} {
+		b, _ := body()
+		parts := readMultipart(t, req, b)
+		if parts["name"].Header.Get("X-Content") != "value" {
+			t.Error("Wrong field value.")
+		}
+		if parts["upload"].FileName() != "data.txt" || parts["upload"].Header.Get("X-Content") != "file content" {
+			t.Error("Wrong file part.")
+		}
+		if parts["meta"].Header.Get("Content-Type") != "application/json" {
+			t.Error("Custom part header not set.")
+		}
+	}
+}
+
+func TestMultipartBodyNotReopenable(t *testing.T) {
+	req := c.EmptyRequest()
+	processor := c.MultipartBody(c.FormFile("upload", "name.bin", strings.NewReader("data")))
+	if err := processor(req); err != nil {
+		t.Fatal("Processor returned error: ", err)
+	}
+	if req.GetBody != nil {
+		t.Error("GetBody set for part that can not be reopened.")
+	}
+	parts := readMultipart(t, req, req.Body)
+	if parts["upload"].Header.Get("X-Content") != "data" {
+		t.Error("Wrong file content.")
+	}
+}
+
+func TestMultipartBodyOpenError(t *testing.T) {
+	req := c.EmptyRequest()
+	processor := c.MultipartBody(c.FormFileFromPath("upload", "/does/not/exist"))
+	if err := processor(req); err != nil {
+		t.Fatal("Processor returned error: ", err)
+	}
+	if _, err := ioutil.ReadAll(req.Body); err == nil {
+		t.Error("Expected error reading body with missing file.")
+	}
+}
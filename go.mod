@@ -1 +1,3 @@
-module "github.com/delicb/cliware"
+module github.com/delicb/cliware
+
+go 1.13
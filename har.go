@@ -0,0 +1,535 @@
+package cliware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultHARBodySize is default limit of captured body size.
+const defaultHARBodySize = 1 << 20
+
+// HAR is root of HTTP Archive (HAR) 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds all recorded entries.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator describes application that created archive.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is single request and response pair.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	// Error is error returned instead of response, if any. It is custom
+	// field, as allowed by HAR specification.
+	Error string `json:"_error,omitempty"`
+}
+
+// HARRequest describes sent request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARResponse describes received response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+// HARNameValue is name and value pair used for headers and query string.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARCookie describes single cookie.
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+// HARPostData describes request body.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARContent describes response body.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings holds durations (in milliseconds) of request phases. Value -1
+// means phase does not apply or is not known.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder is Middleware that records all requests and responses passing
+// through it in HTTP Archive (HAR) 1.2 format, which can be opened in
+// browser developer tools.
+//
+// Response is recorded completely once its body is read to the end or
+// closed. Bodies are recorded up to MaxBodySize bytes.
+type HARRecorder struct {
+	// MaxBodySize is maximal number of bytes of request and response body
+	// that is recorded. Zero disables recording of bodies.
+	MaxBodySize int64
+
+	// Trace enables detailed timings (DNS, connect, TLS, etc.) using
+	// net/http/httptrace. Without it, only wait and receive timings are
+	// known.
+	Trace bool
+
+	// Redact, if set, is called for each entry before it is returned or
+	// written, so secrets (e.g. Authorization header) can be removed.
+	Redact func(entry *HAREntry)
+
+	mu      sync.Mutex
+	entries []*harRecord
+}
+
+// harRecord is entry that is being recorded.
+type harRecord struct {
+	entry       HAREntry
+	start       time.Time
+	trace       harTrace
+	requestBody *limitedBuffer
+	body        *limitedBuffer
+	bodyDone    time.Time
+	responded   time.Time
+}
+
+// harTrace holds times collected by httptrace.
+type harTrace struct {
+	getConn, dnsStart, dnsDone, connectStart, connectDone time.Time
+	tlsStart, tlsDone, gotConn, wroteRequest, firstByte   time.Time
+}
+
+// NewHARRecorder creates HARRecorder that records bodies up to 1MB.
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{
+		MaxBodySize: defaultHARBodySize,
+	}
+}
+
+// Exec is implementation of Middleware interface.
+func (r *HARRecorder) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		record := &harRecord{start: time.Now()}
+		if r.Trace {
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), r.clientTrace(record)))
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			record.requestBody = &limitedBuffer{limit: r.MaxBodySize}
+			req.Body = &capturingBody{ReadCloser: req.Body, buf: record.requestBody, mu: &r.mu}
+		}
+		r.mu.Lock()
+		record.entry = harRequestEntry(req)
+		r.entries = append(r.entries, record)
+		r.mu.Unlock()
+
+		resp, err = next.Handle(req)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		record.responded = time.Now()
+		if err != nil {
+			record.entry.Error = err.Error()
+		}
+		if resp != nil {
+			record.entry.Response = harResponse(resp)
+			if resp.Body != nil && resp.Body != http.NoBody {
+				record.body = &limitedBuffer{limit: r.MaxBodySize}
+				resp.Body = &capturingBody{ReadCloser: resp.Body, buf: record.body, mu: &r.mu, done: &record.bodyDone}
+			}
+		}
+		return resp, err
+	})
+}
+
+// Entries returns all entries recorded so far.
+func (r *HARRecorder) Entries() []HAREntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finish(r.entries)
+}
+
+// WriteTo writes all entries recorded so far to provided writer as HAR
+// document. It is implementation of io.WriterTo interface.
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	return writeHAR(w, r.Entries())
+}
+
+// Flush writes all entries recorded so far to provided writer (see WriteTo)
+// and removes them from recorder.
+func (r *HARRecorder) Flush(w io.Writer) error {
+	r.mu.Lock()
+	entries := r.finish(r.entries)
+	r.entries = nil
+	r.mu.Unlock()
+	_, err := writeHAR(w, entries)
+	return err
+}
+
+// finish returns entries for provided records, with redaction applied.
+// Has to be called with lock held.
+func (r *HARRecorder) finish(records []*harRecord) []HAREntry {
+	entries := make([]HAREntry, 0, len(records))
+	for _, record := range records {
+		entry := record.finish()
+		if r.Redact != nil {
+			r.Redact(&entry)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// writeHAR writes provided entries to writer as HAR document.
+func writeHAR(w io.Writer, entries []HAREntry) (int64, error) {
+	har := HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "cliware", Version: "1.0"},
+		Entries: entries,
+	}}
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// clientTrace returns trace that records timings into provided record.
+func (r *HARRecorder) clientTrace(record *harRecord) *httptrace.ClientTrace {
+	set := func(t *time.Time) {
+		r.mu.Lock()
+		if t.IsZero() {
+			*t = time.Now()
+		}
+		r.mu.Unlock()
+	}
+	tr := &record.trace
+	return &httptrace.ClientTrace{
+		GetConn:              func(string) { set(&tr.getConn) },
+		DNSStart:             func(httptrace.DNSStartInfo) { set(&tr.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { set(&tr.dnsDone) },
+		ConnectStart:         func(string, string) { set(&tr.connectStart) },
+		ConnectDone:          func(string, string, error) { set(&tr.connectDone) },
+		TLSHandshakeStart:    func() { set(&tr.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { set(&tr.tlsDone) },
+		GotConn:              func(httptrace.GotConnInfo) { set(&tr.gotConn) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { set(&tr.wroteRequest) },
+		GotFirstResponseByte: func() { set(&tr.firstByte) },
+	}
+}
+
+// finish returns copy of entry with bodies and timings filled in. Has to be
+// called with lock held.
+func (record *harRecord) finish() HAREntry {
+	entry := record.entry
+	// slices are copied, so redaction does not change recorded entry
+	entry.Request.Headers = append([]HARNameValue{}, entry.Request.Headers...)
+	entry.Request.QueryString = append([]HARNameValue{}, entry.Request.QueryString...)
+	entry.Request.Cookies = append([]HARCookie{}, entry.Request.Cookies...)
+	entry.Response.Headers = append([]HARNameValue{}, entry.Response.Headers...)
+	entry.Response.Cookies = append([]HARCookie{}, entry.Response.Cookies...)
+	if record.requestBody != nil {
+		text, encoding := harText(record.requestBody.Bytes())
+		entry.Request.PostData = &HARPostData{
+			MimeType: headerValue(entry.Request.Headers, "Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+		}
+		entry.Request.BodySize = record.requestBody.total
+	}
+	if record.body != nil {
+		entry.Response.Content.Text, entry.Response.Content.Encoding = harText(record.body.Bytes())
+		entry.Response.Content.Size = record.body.total
+		entry.Response.BodySize = record.body.total
+	}
+
+	end := record.responded
+	if !record.bodyDone.IsZero() {
+		end = record.bodyDone
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	entry.StartedDateTime = record.start.Format(time.RFC3339Nano)
+	entry.Time = millis(end.Sub(record.start))
+	entry.Timings = record.timings(end)
+	return entry
+}
+
+// timings calculates HAR timings from collected times.
+func (record *harRecord) timings(end time.Time) HARTimings {
+	t := record.trace
+	timings := HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	between := func(start, end time.Time) float64 {
+		if start.IsZero() || end.IsZero() {
+			return -1
+		}
+		return millis(end.Sub(start))
+	}
+	responded := record.responded
+	if responded.IsZero() {
+		responded = end
+	}
+	if t.gotConn.IsZero() {
+		// no trace information, everything until response is waiting
+		timings.Wait = millis(responded.Sub(record.start))
+		timings.Receive = millis(end.Sub(responded))
+		return timings
+	}
+
+	connStart := t.gotConn
+	for _, candidate := range []time.Time{t.connectStart, t.dnsStart} {
+		if !candidate.IsZero() && candidate.Before(connStart) {
+			connStart = candidate
+		}
+	}
+	timings.Blocked = between(t.getConn, connStart)
+	timings.DNS = between(t.dnsStart, t.dnsDone)
+	timings.Connect = between(t.connectStart, t.tlsDone)
+	if timings.Connect < 0 {
+		timings.Connect = between(t.connectStart, t.connectDone)
+	}
+	timings.SSL = between(t.tlsStart, t.tlsDone)
+	timings.Send = between(t.gotConn, t.wroteRequest)
+	if timings.Send < 0 {
+		timings.Send = 0
+	}
+	firstByte := t.firstByte
+	if firstByte.IsZero() {
+		firstByte = responded
+	}
+	timings.Wait = between(t.wroteRequest, firstByte)
+	if timings.Wait < 0 {
+		timings.Wait = between(t.gotConn, firstByte)
+	}
+	timings.Receive = millis(end.Sub(firstByte))
+	return timings
+}
+
+// harRequestEntry creates entry with request information.
+func harRequestEntry(req *http.Request) HAREntry {
+	entry := HAREntry{
+		Request: HARRequest{
+			Method:      req.Method,
+			HTTPVersion: req.Proto,
+			Cookies:     []HARCookie{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []HARNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: HARResponse{
+			Cookies:     []HARCookie{},
+			Headers:     []HARNameValue{},
+			HTTPVersion: "",
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+	}
+	if entry.Request.Method == "" {
+		entry.Request.Method = "GET"
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	if req.URL != nil {
+		entry.Request.URL = req.URL.String()
+		entry.Request.QueryString = harValues(req.URL.Query())
+	}
+	for _, c := range req.Cookies() {
+		entry.Request.Cookies = append(entry.Request.Cookies, HARCookie{Name: c.Name, Value: c.Value})
+	}
+	return entry
+}
+
+// harResponse creates response description without body.
+func harResponse(resp *http.Response) HARResponse {
+	response := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARCookie{},
+		Headers:     harHeaders(resp.Header),
+		Content:     HARContent{MimeType: resp.Header.Get("Content-Type")},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if response.HTTPVersion == "" {
+		response.HTTPVersion = "HTTP/1.1"
+	}
+	for _, c := range resp.Cookies() {
+		cookie := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			cookie.Expires = c.Expires.Format(time.RFC3339)
+		}
+		response.Cookies = append(response.Cookies, cookie)
+	}
+	return response
+}
+
+// harHeaders converts headers to sorted list of name value pairs.
+func harHeaders(header http.Header) []HARNameValue {
+	return harValues(map[string][]string(header))
+}
+
+// harValues converts multi value map to sorted list of name value pairs.
+func harValues(values map[string][]string) []HARNameValue {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := []HARNameValue{}
+	for _, k := range keys {
+		for _, v := range values[k] {
+			pairs = append(pairs, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return pairs
+}
+
+// headerValue returns first value of header with provided name.
+func headerValue(headers []HARNameValue, name string) string {
+	for _, h := range headers {
+		if http.CanonicalHeaderKey(h.Name) == name {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// harText returns body as text, base64 encoded if it is not valid UTF-8.
+func harText(body []byte) (text, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// millis converts duration to milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// limitedBuffer keeps up to limit bytes written to it, but counts all
+// of them.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+	total int64
+}
+
+// keep stores provided bytes, as long as limit is not reached.
+func (b *limitedBuffer) keep(p []byte) {
+	b.total += int64(len(p))
+	if room := b.limit - int64(b.Len()); room > 0 {
+		if int64(len(p)) > room {
+			p = p[:room]
+		}
+		b.Write(p)
+	}
+}
+
+// capturingBody is body wrapper that copies read bytes to buffer.
+type capturingBody struct {
+	io.ReadCloser
+	buf  *limitedBuffer
+	mu   *sync.Mutex
+	done *time.Time
+}
+
+// Read is implementation of io.Reader interface.
+func (b *capturingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.buf.keep(p[:n])
+	if err != nil {
+		b.markDone()
+	}
+	b.mu.Unlock()
+	return n, err
+}
+
+// Close is implementation of io.Closer interface.
+func (b *capturingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	b.markDone()
+	b.mu.Unlock()
+	return err
+}
+
+// markDone records time body was consumed. Has to be called with lock held.
+func (b *capturingBody) markDone() {
+	if b.done != nil && b.done.IsZero() {
+		*b.done = time.Now()
+	}
+}
@@ -0,0 +1,101 @@
+package cliware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("response body"))
+	}))
+	defer server.Close()
+
+	recorder := c.NewHARRecorder()
+	recorder.Trace = true
+	recorder.Redact = func(entry *c.HAREntry) {
+		for i, h := range entry.Request.Headers {
+			if h.Name == "Authorization" {
+				entry.Request.Headers[i].Value = "REDACTED"
+			}
+		}
+	}
+	req, _ := http.NewRequest("POST", server.URL+"/items?page=2", strings.NewReader("request body"))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.AddCookie(&http.Cookie{Name: "pref", Value: "dark"})
+	resp, err := recorder.Exec(c.HandlerFunc(sender)).Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var buf bytes.Buffer
+	if err := recorder.Flush(&buf); err != nil {
+		t.Fatal("Flush returned error: ", err)
+	}
+	if len(recorder.Entries()) != 0 {
+		t.Error("Entries not removed after flush.")
+	}
+	var har c.HAR
+	if err := json.Unmarshal(buf.Bytes(), &har); err != nil {
+		t.Fatal("Invalid HAR JSON: ", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 1 {
+		t.Fatalf("Unexpected HAR log: %+v", har.Log)
+	}
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != "POST" || entry.Request.PostData == nil || entry.Request.PostData.Text != "request body" {
+		t.Errorf("Wrong request: %+v", entry.Request)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0].Value != "2" {
+		t.Errorf("Wrong query string: %+v", entry.Request.QueryString)
+	}
+	if len(entry.Request.Cookies) != 1 || entry.Request.Cookies[0].Name != "pref" {
+		t.Errorf("Wrong request cookies: %+v", entry.Request.Cookies)
+	}
+	for _, h := range entry.Request.Headers {
+		if h.Name == "Authorization" && h.Value != "REDACTED" {
+			t.Error("Authorization header not redacted.")
+		}
+	}
+	if entry.Response.Status != 200 || entry.Response.Content.Text != "response body" || entry.Response.Content.Size != 13 {
+		t.Errorf("Wrong response: %+v", entry.Response)
+	}
+	if len(entry.Response.Cookies) != 1 || entry.Response.Cookies[0].Value != "abc" {
+		t.Errorf("Wrong response cookies: %+v", entry.Response.Cookies)
+	}
+	if entry.Timings.Connect < 0 || entry.Timings.Wait < 0 || entry.Time <= 0 {
+		t.Errorf("Wrong timings: %+v, time: %f", entry.Timings, entry.Time)
+	}
+}
+
+func TestHARRecorderBodyLimitAndError(t *testing.T) {
+	recorder := c.NewHARRecorder()
+	recorder.MaxBodySize = 4
+	resp, _ := recorder.Exec(bodyHandler("0123456789")).Handle(c.EmptyRequest())
+	ioutil.ReadAll(resp.Body)
+	recorder.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, http.ErrHandlerTimeout
+	})).Handle(c.EmptyRequest())
+
+	entries := recorder.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries, got: %d", len(entries))
+	}
+	if entries[0].Response.Content.Text != "0123" || entries[0].Response.Content.Size != 10 {
+		t.Errorf("Body limit not applied: %+v", entries[0].Response.Content)
+	}
+	if entries[1].Error != http.ErrHandlerTimeout.Error() {
+		t.Errorf("Error not recorded: %q", entries[1].Error)
+	}
+}
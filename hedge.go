@@ -0,0 +1,209 @@
+package cliware
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// hedgeWindow is number of latest latency samples Hedger keeps.
+	hedgeWindow = 128
+	// hedgeMinSamples is number of samples Hedger needs before it starts
+	// using observed latency percentile instead of fixed delay.
+	hedgeMinSamples = 10
+)
+
+// Hedger is Middleware that sends duplicate (hedged) request to next Handler
+// if first attempt did not return in time. First successful response wins,
+// other attempts are canceled and their bodies are drained and closed.
+//
+// Since same request is sent multiple times, by default only idempotent
+// requests are hedged. Request body is buffered (see RewindableBody) if it
+// can not be reopened, so each attempt reads its own copy. Hedger does not
+// retry failed attempts, if all sent attempts fail, last failure is returned.
+type Hedger struct {
+	// Delay is time to wait for previous attempt before sending next one.
+	// It is also used as fallback when Percentile is set, but not enough
+	// latencies have been observed yet.
+	Delay time.Duration
+
+	// Percentile, if set to value between 0 and 1, makes Hedger wait for
+	// given percentile (e.g. 0.95) of observed latency before sending next
+	// attempt.
+	Percentile float64
+
+	// MaxAttempts is maximal number of attempts (including first one).
+	// Values lower than 2 mean 2.
+	MaxAttempts int
+
+	// ShouldHedge decides if request will be hedged. If nil, IsIdempotent
+	// is used.
+	ShouldHedge func(req *http.Request) bool
+
+	// IsSuccess decides if attempt result can be returned to caller. If nil,
+	// every response without error and with status code lower than 500
+	// is considered successful.
+	IsSuccess func(resp *http.Response, err error) bool
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	next      int
+}
+
+// NewHedger creates Hedger that sends one additional attempt if first one
+// did not return within provided delay.
+func NewHedger(delay time.Duration) *Hedger {
+	return &Hedger{
+		Delay:       delay,
+		MaxAttempts: 2,
+	}
+}
+
+// IsIdempotent returns true if request method is idempotent as defined by
+// RFC 7231, meaning it is safe to send the same request more than once.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", "GET", "HEAD", "OPTIONS", "TRACE", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// hedgeResult is result of single attempt.
+type hedgeResult struct {
+	index   int
+	resp    *http.Response
+	err     error
+	latency time.Duration
+}
+
+// Exec is implementation of Middleware interface.
+func (h *Hedger) Exec(next Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		shouldHedge := h.ShouldHedge
+		if shouldHedge == nil {
+			shouldHedge = IsIdempotent
+		}
+		if !shouldHedge(req) {
+			return next.Handle(req)
+		}
+		if err := RewindableBody(req); err != nil {
+			return nil, err
+		}
+
+		maxAttempts := h.MaxAttempts
+		if maxAttempts < 2 {
+			maxAttempts = 2
+		}
+		results := make(chan hedgeResult, maxAttempts)
+		cancels := make([]context.CancelFunc, 0, maxAttempts)
+		launch := func() error {
+			ctx, cancel := context.WithCancel(req.Context())
+			attempt, err := CloneRequest(ctx, req)
+			if err != nil {
+				cancel()
+				return err
+			}
+			index := len(cancels)
+			cancels = append(cancels, cancel)
+			go func() {
+				start := time.Now()
+				resp, err := next.Handle(attempt)
+				results <- hedgeResult{index: index, resp: resp, err: err, latency: time.Since(start)}
+			}()
+			return nil
+		}
+
+		if err := launch(); err != nil {
+			return nil, err
+		}
+		delay := h.delay()
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		var last hedgeResult
+		for received := 0; received < len(cancels); {
+			select {
+			case res := <-results:
+				received++
+				if h.isSuccess(res.resp, res.err) {
+					h.observe(res.latency)
+					DrainBody(last.resp)
+					return h.finish(res, cancels, results, len(cancels)-received)
+				}
+				DrainBody(last.resp)
+				last = res
+			case <-timer.C:
+				if len(cancels) < maxAttempts {
+					if err := launch(); err == nil {
+						timer.Reset(delay)
+					}
+				}
+			}
+		}
+		return h.finish(last, cancels, results, 0)
+	})
+}
+
+// finish cancels all attempts except provided one and discards results of
+// attempts that are still running. Context of returned attempt is canceled
+// once response body is closed.
+func (h *Hedger) finish(res hedgeResult, cancels []context.CancelFunc, results chan hedgeResult, pending int) (*http.Response, error) {
+	for i, cancel := range cancels {
+		if i != res.index {
+			cancel()
+		}
+	}
+	if pending > 0 {
+		go func() {
+			for i := 0; i < pending; i++ {
+				DrainBody((<-results).resp)
+			}
+		}()
+	}
+	if res.resp != nil && res.resp.Body != nil {
+		res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.index]}
+	} else {
+		cancels[res.index]()
+	}
+	return res.resp, res.err
+}
+
+func (h *Hedger) isSuccess(resp *http.Response, err error) bool {
+	if h.IsSuccess != nil {
+		return h.IsSuccess(resp, err)
+	}
+	return err == nil && resp != nil && resp.StatusCode < 500
+}
+
+// delay returns time to wait before sending next attempt.
+func (h *Hedger) delay() time.Duration {
+	if h.Percentile <= 0 || h.Percentile > 1 {
+		return h.Delay
+	}
+	h.mu.Lock()
+	samples := make([]time.Duration, len(h.latencies))
+	copy(samples, h.latencies)
+	h.mu.Unlock()
+	if len(samples) < hedgeMinSamples {
+		return h.Delay
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	index := int(float64(len(samples)-1) * h.Percentile)
+	return samples[index]
+}
+
+// observe records latency of successful attempt.
+func (h *Hedger) observe(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeWindow {
+		h.latencies = append(h.latencies, latency)
+		return
+	}
+	h.latencies[h.next] = latency
+	h.next = (h.next + 1) % hedgeWindow
+}
@@ -0,0 +1,109 @@
+package cliware_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	c "github.com/delicb/cliware"
+)
+
+// slowFirstHandler returns handler whose first call blocks until request
+// context is done and other calls respond with request body.
+func slowFirstHandler(calls *int32, canceled chan struct{}) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		if atomic.AddInt32(calls, 1) == 1 {
+			<-req.Context().Done()
+			close(canceled)
+			return nil, req.Context().Err()
+		}
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader(string(body))),
+			Request:    req,
+		}, nil
+	})
+}
+
+func TestHedgerSendsSecondAttempt(t *testing.T) {
+	var calls int32
+	canceled := make(chan struct{})
+	hedger := c.NewHedger(10 * time.Millisecond)
+	handler := hedger.Exec(slowFirstHandler(&calls, canceled))
+
+	req, _ := http.NewRequest("PUT", "http://localhost", strings.NewReader("payload"))
+	resp, err := handler.Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "payload" {
+		t.Errorf("Hedged attempt got wrong body: %q", body)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("Losing attempt was not canceled.")
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expected 2 attempts, got: %d", calls)
+	}
+}
+
+func TestHedgerSkipsNonIdempotent(t *testing.T) {
+	var calls int32
+	hedger := c.NewHedger(time.Millisecond)
+	handler := hedger.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	}))
+
+	req, _ := http.NewRequest("POST", "http://localhost", nil)
+	_, err := handler.Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected 1 attempt for POST request, got: %d", calls)
+	}
+}
+
+func TestHedgerFastResponse(t *testing.T) {
+	var calls int32
+	hedger := c.NewHedger(time.Second)
+	handler := hedger.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	}))
+
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got: %d", resp.StatusCode)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected 1 attempt, got: %d", calls)
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for method, expected := range map[string]bool{
+		"GET": true, "PUT": true, "DELETE": true, "POST": false, "PATCH": false,
+	} {
+		req, _ := http.NewRequest(method, "http://localhost", nil)
+		if c.IsIdempotent(req) != expected {
+			t.Errorf("Wrong idempotency for %s, expected: %t", method, expected)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package cliware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is default name of header that carries idempotency key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKeyContextKey is key under which idempotency key is stored in
+// request context.
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey returns context that carries provided idempotency key.
+// It can be used by callers to supply their own key for single request.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns idempotency key stored in provided
+// context, if any.
+func IdempotencyKeyFromContext(ctx context.Context) (key string, ok bool) {
+	key, ok = ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+// IdempotencyKey returns Middleware that sets idempotency key header on
+// POST and PATCH requests, so servers can safely deduplicate them.
+//
+// Key is chosen in following order: key already stored in request context
+// (see WithIdempotencyKey), value of header if it is already set on request
+// and finally new key returned by generate function. If generate is nil,
+// random UUID is used. If header is empty, IdempotencyKeyHeader is used.
+//
+// Chosen key is stored in context of request passed to next handler, so
+// middlewares later in chain that send request multiple times (retries,
+// hedging) reuse the same key, even if they run this middleware again.
+func IdempotencyKey(header string, generate func() (string, error)) Middleware {
+	if header == "" {
+		header = IdempotencyKeyHeader
+	}
+	if generate == nil {
+		generate = randomUUID
+	}
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "POST" && req.Method != "PATCH" {
+				return next.Handle(req)
+			}
+			key, ok := IdempotencyKeyFromContext(req.Context())
+			if !ok {
+				key = req.Header.Get(header)
+			}
+			if key == "" {
+				var err error
+				key, err = generate()
+				if err != nil {
+					return nil, err
+				}
+			}
+			req.Header.Set(header, key)
+			if !ok {
+				req = req.WithContext(WithIdempotencyKey(req.Context(), key))
+			}
+			return next.Handle(req)
+		})
+	})
+}
+
+// randomUUID returns random (version 4) UUID.
+func randomUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
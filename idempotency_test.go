@@ -0,0 +1,99 @@
+package cliware_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+// requestCapture returns handler that stores request it receives.
+func requestCapture(captured **http.Request) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		*captured = req
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+}
+
+func TestIdempotencyKeyGenerated(t *testing.T) {
+	var sent *http.Request
+	handler := c.IdempotencyKey("", nil).Exec(requestCapture(&sent))
+	req, _ := http.NewRequest("POST", "http://localhost", nil)
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	key := sent.Header.Get(c.IdempotencyKeyHeader)
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(key) {
+		t.Errorf("Generated key is not valid UUID: %s", key)
+	}
+	stored, ok := c.IdempotencyKeyFromContext(sent.Context())
+	if !ok || stored != key {
+		t.Errorf("Key not stored in context. Got: %s, expected: %s", stored, key)
+	}
+	if _, ok := c.IdempotencyKeyFromContext(req.Context()); ok {
+		t.Error("Caller's request was modified.")
+	}
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	var generated int
+	middleware := c.IdempotencyKey("X-Request-Key", func() (string, error) {
+		generated++
+		return "generated", nil
+	})
+	// retry middleware that sends fresh copy of request, without headers,
+	// through the same middleware again
+	var keys []string
+	retry := c.MiddlewareFunc(func(next c.Handler) c.Handler {
+		return c.HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+			for i := 0; i < 3; i++ {
+				attempt := req.WithContext(req.Context())
+				attempt.Header = http.Header{}
+				var sent *http.Request
+				if resp, err = middleware.Exec(requestCapture(&sent)).Handle(attempt); err != nil {
+					return nil, err
+				}
+				keys = append(keys, sent.Header.Get("X-Request-Key"))
+			}
+			return resp, err
+		})
+	})
+	req, _ := http.NewRequest("PATCH", "http://localhost", nil)
+	if _, err := c.NewChain(middleware, retry).Exec(nil).Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	for _, key := range keys {
+		if key != "generated" {
+			t.Errorf("Wrong key in header: %s", key)
+		}
+	}
+	if len(keys) != 3 || generated != 1 {
+		t.Errorf("Expected key to be generated once for 3 attempts, got: %d, %d", generated, len(keys))
+	}
+}
+
+func TestIdempotencyKeyFromCaller(t *testing.T) {
+	var sent *http.Request
+	handler := c.IdempotencyKey("", nil).Exec(requestCapture(&sent))
+	req, _ := http.NewRequest("POST", "http://localhost", nil)
+	req = req.WithContext(c.WithIdempotencyKey(req.Context(), "caller-key"))
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if sent.Header.Get(c.IdempotencyKeyHeader) != "caller-key" {
+		t.Errorf("Caller key not used, got: %s", sent.Header.Get(c.IdempotencyKeyHeader))
+	}
+}
+
+func TestIdempotencyKeySkipsSafeMethods(t *testing.T) {
+	var sent *http.Request
+	handler := c.IdempotencyKey("", nil).Exec(requestCapture(&sent))
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if sent.Header.Get(c.IdempotencyKeyHeader) != "" {
+		t.Error("Idempotency key set on GET request.")
+	}
+}
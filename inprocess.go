@@ -0,0 +1,227 @@
+package cliware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InProcessHandler returns final Handler that serves requests with provided
+// server side http.Handler directly, without any networking. It is useful
+// for testing client middleware chains against real server mux and for
+// service to service calls within single binary.
+//
+// Server handler runs in its own goroutine. Response is returned as soon as
+// handler writes headers (explicitly, by writing body or by flushing) or
+// returns, and body is streamed to caller while handler writes it, so caller
+// has to close response body, just like with network responses. Trailers
+// are available in response after body has been read completely.
+func InProcessHandler(h http.Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		serverReq := serverRequest(req)
+		pr, pw := io.Pipe()
+		w := &inProcessWriter{
+			req:       req,
+			header:    make(http.Header),
+			pw:        pw,
+			pr:        pr,
+			committed: make(chan *http.Response, 1),
+			head:      req.Method == "HEAD",
+		}
+		failed := make(chan error, 1)
+
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					err := fmt.Errorf("cliware: in-process handler panic: %v", r)
+					w.mu.Lock()
+					committed := w.response != nil
+					w.mu.Unlock()
+					if !committed {
+						failed <- err
+					}
+					_ = pw.CloseWithError(err)
+				}
+			}()
+			h.ServeHTTP(w, serverReq)
+			w.finish()
+		}()
+
+		select {
+		case resp := <-w.committed:
+			return resp, nil
+		case err := <-failed:
+			return nil, err
+		}
+	})
+}
+
+// serverRequest converts client request to request as server handler would
+// receive it.
+func serverRequest(req *http.Request) *http.Request {
+	serverReq := req.WithContext(req.Context())
+	serverReq.Header = cloneHeader(req.Header)
+	if serverReq.Header == nil {
+		serverReq.Header = make(http.Header)
+	}
+	if serverReq.Method == "" {
+		serverReq.Method = "GET"
+	}
+	if serverReq.Body == nil {
+		serverReq.Body = http.NoBody
+	}
+	if req.URL != nil {
+		serverReq.RequestURI = req.URL.RequestURI()
+		if serverReq.Host == "" {
+			serverReq.Host = req.URL.Host
+		}
+	}
+	if serverReq.Proto == "" {
+		serverReq.Proto, serverReq.ProtoMajor, serverReq.ProtoMinor = "HTTP/1.1", 1, 1
+	}
+	if serverReq.RemoteAddr == "" {
+		serverReq.RemoteAddr = "127.0.0.1:0"
+	}
+	return serverReq
+}
+
+// inProcessWriter is http.ResponseWriter that streams response to client
+// through pipe.
+type inProcessWriter struct {
+	req       *http.Request
+	header    http.Header
+	pw        *io.PipeWriter
+	pr        *io.PipeReader
+	head      bool
+	committed chan *http.Response
+
+	mu          sync.Mutex
+	status      int
+	response    *http.Response
+	trailerKeys []string
+}
+
+// Header is implementation of http.ResponseWriter interface.
+func (w *inProcessWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader is implementation of http.ResponseWriter interface.
+func (w *inProcessWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// Write is implementation of http.ResponseWriter interface.
+func (w *inProcessWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.response == nil && w.header.Get("Content-Type") == "" && len(p) > 0 {
+		w.header.Set("Content-Type", http.DetectContentType(p))
+	}
+	w.commit(-1)
+	w.mu.Unlock()
+	if w.head {
+		return len(p), nil
+	}
+	return w.pw.Write(p)
+}
+
+// Flush is implementation of http.Flusher interface. Flushing commits
+// headers, body is not buffered anyway.
+func (w *inProcessWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.commit(-1)
+}
+
+// finish is called after handler returns. It commits response if handler
+// did not write anything, sets trailers and ends the body.
+func (w *inProcessWriter) finish() {
+	w.mu.Lock()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.commit(0)
+	resp := w.response
+	for _, key := range w.trailerKeys {
+		if values, ok := w.header[key]; ok {
+			resp.Trailer[key] = values
+		}
+	}
+	for key, values := range w.header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			if resp.Trailer == nil {
+				resp.Trailer = make(http.Header)
+			}
+			resp.Trailer[http.CanonicalHeaderKey(strings.TrimPrefix(key, http.TrailerPrefix))] = values
+		}
+	}
+	w.mu.Unlock()
+	_ = w.pw.Close()
+}
+
+// commit creates response from current status and headers and sends it to
+// waiting client, if that was not done already. Provided length is used
+// as content length if Content-Length header is not set. Has to be called
+// with lock held.
+func (w *inProcessWriter) commit(length int64) {
+	if w.response != nil {
+		return
+	}
+	header := cloneHeader(w.header)
+	var trailer http.Header
+	for _, declared := range header["Trailer"] {
+		for _, key := range strings.Split(declared, ",") {
+			key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+			if key == "" {
+				continue
+			}
+			if trailer == nil {
+				trailer = make(http.Header)
+			}
+			trailer[key] = nil
+			w.trailerKeys = append(w.trailerKeys, key)
+		}
+	}
+	delete(header, "Trailer")
+	for key := range header {
+		if strings.HasPrefix(key, http.TrailerPrefix) {
+			delete(header, key)
+		}
+	}
+	if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		length = cl
+	}
+	if w.head {
+		length = -1
+		if cl, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+			length = cl
+		}
+	}
+
+	w.response = &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Trailer:       trailer,
+		Body:          w.pr,
+		ContentLength: length,
+		Request:       w.req,
+	}
+	w.committed <- w.response
+}
@@ -0,0 +1,98 @@
+package cliware_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestInProcessHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Request-URI", r.RequestURI)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("User-Agent"), body)
+	})
+	chain := c.NewChain(c.RequestProcessor(func(req *http.Request) error {
+		req.Header.Set("User-Agent", "cliware")
+		return nil
+	}))
+	req, _ := http.NewRequest("POST", "http://service/hello?x=1", strings.NewReader("payload"))
+	resp, err := chain.Exec(c.InProcessHandler(mux)).Handle(req)
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated || string(body) != "POST cliware payload" {
+		t.Errorf("Wrong response: %d %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Request-URI") != "/hello?x=1" {
+		t.Errorf("Wrong request URI seen by server: %s", resp.Header.Get("X-Request-URI"))
+	}
+	if resp.Request != req || resp.Header.Get("Content-Type") == "" {
+		t.Error("Response request or content type not set.")
+	}
+}
+
+func TestInProcessHandlerContentLength(t *testing.T) {
+	handler := c.InProcessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.ContentLength != 0 {
+		t.Errorf("Wrong response: %d, content length: %d", resp.StatusCode, resp.ContentLength)
+	}
+}
+
+func TestInProcessHandlerStreamingAndTrailers(t *testing.T) {
+	proceed := make(chan struct{})
+	handler := c.InProcessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		fmt.Fprintln(w, "first")
+		w.(http.Flusher).Flush()
+		<-proceed
+		fmt.Fprintln(w, "second")
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	if _, ok := resp.Trailer["X-Checksum"]; !ok {
+		t.Error("Declared trailer not present in response.")
+	}
+	reader := bufio.NewReader(resp.Body)
+	line, _ := reader.ReadString('\n')
+	if line != "first\n" {
+		t.Errorf("Wrong first line: %q", line)
+	}
+	close(proceed)
+	rest, _ := ioutil.ReadAll(reader)
+	if string(rest) != "second\n" {
+		t.Errorf("Wrong rest of body: %q", rest)
+	}
+	if resp.Trailer.Get("X-Checksum") != "abc" {
+		t.Errorf("Wrong trailer value: %q", resp.Trailer.Get("X-Checksum"))
+	}
+}
+
+func TestInProcessHandlerPanic(t *testing.T) {
+	handler := c.InProcessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	if _, err := handler.Handle(c.EmptyRequest()); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected panic error, got: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package cliware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrStopDecoding can be returned from callback of DecodeJSONStream to stop
+// decoding early. DecodeJSONStream then returns nil.
+var ErrStopDecoding = errors.New("cliware: stop decoding")
+
+// JSONStream decodes elements of streamed JSON response one by one, without
+// buffering entire body. Supported formats are newline delimited JSON
+// (application/x-ndjson, application/jsonl, etc.), which is really just
+// sequence of JSON values, and top level JSON array, whose elements are
+// decoded one by one.
+//
+// JSONStream is used as iterator:
+//
+//	stream := NewJSONStream(ctx, resp)
+//	defer stream.Close()
+//	for stream.Next() {
+//		var item Item
+//		if err := stream.Decode(&item); err != nil {
+//			...
+//		}
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type JSONStream struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	decoder *json.Decoder
+	started bool
+	ndjson  bool
+	array   bool
+	decoded bool
+	err     error
+}
+
+// NewJSONStream creates JSONStream that reads body of provided response.
+// Responses with newline delimited JSON media type are always treated as
+// sequence of JSON values, even if values are arrays. For other responses
+// format is detected from first non whitespace character of body: if it is
+// '[' elements of array are decoded, otherwise body is treated as sequence of
+// JSON values. Decoding stops when provided context is done.
+//
+// Context is checked only between elements, so read that is stalled in the
+// middle of element is not interrupted by it. To interrupt such reads, use
+// context of request response belongs to (or context derived from it), which
+// makes transport abort reading body.
+func NewJSONStream(ctx context.Context, resp *http.Response) *JSONStream {
+	return &JSONStream{
+		ctx:     ctx,
+		body:    resp.Body,
+		decoder: json.NewDecoder(resp.Body),
+		ndjson:  isNDJSON(resp),
+	}
+}
+
+// Next returns true if there is next element to decode. It returns false at
+// the end of stream or on error. Use Err to check which one was it.
+func (s *JSONStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+		return false
+	}
+	if s.started && !s.decoded {
+		// previous element was not decoded, skip it
+		var skip json.RawMessage
+		if err := s.decoder.Decode(&skip); err != nil {
+			s.err = err
+			return false
+		}
+	}
+	if !s.started {
+		s.started = true
+		if err := s.start(); err != nil {
+			s.err = err
+			return false
+		}
+	}
+	s.decoded = false
+	if !s.decoder.More() {
+		if s.array {
+			s.err = s.expectDelim(']')
+		}
+		if s.err == nil {
+			s.err = s.end()
+		}
+		return false
+	}
+	return true
+}
+
+// Decode decodes current element into provided value. It has to be called
+// at most once after each call to Next that returned true.
+func (s *JSONStream) Decode(v interface{}) error {
+	if s.decoded {
+		return errors.New("cliware: element already decoded")
+	}
+	s.decoded = true
+	if err := s.decoder.Decode(v); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+// Err returns error that stopped decoding, if any. Reaching end of stream
+// is not considered an error.
+func (s *JSONStream) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// Close closes response body. It is safe to call it before stream is
+// consumed, e.g. to stop downloading rest of large response.
+func (s *JSONStream) Close() error {
+	return s.body.Close()
+}
+
+// start detects stream format and consumes opening array bracket, if any.
+func (s *JSONStream) start() error {
+	if !s.decoder.More() {
+		return s.end()
+	}
+	if s.ndjson {
+		return nil
+	}
+	// More makes sure start of first value is buffered, so it can be
+	// inspected without consuming it
+	buffered, err := ioutil.ReadAll(s.decoder.Buffered())
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(bytes.TrimLeft(buffered, " \t\r\n"), []byte("[")) {
+		s.array = true
+		return s.expectDelim('[')
+	}
+	return nil
+}
+
+// end returns io.EOF if stream has been consumed or error that prevented
+// reading next value.
+func (s *JSONStream) end() error {
+	token, err := s.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if s.array {
+		return fmt.Errorf("cliware: unexpected %v after JSON array", token)
+	}
+	return fmt.Errorf("cliware: unexpected %v in JSON stream", token)
+}
+
+// expectDelim reads next token and checks it is expected delimiter.
+func (s *JSONStream) expectDelim(expected json.Delim) error {
+	token, err := s.decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("cliware: expected %q in JSON stream, got: %v", expected, token)
+	}
+	return nil
+}
+
+// DecodeJSONStream decodes streamed JSON response (see JSONStream) calling
+// provided callback for each element. Callback receives function that
+// decodes current element into provided value. If callback returns error,
+// decoding stops and error is returned, except for ErrStopDecoding (also
+// wrapped) which stops decoding without error. Response body is always
+// closed.
+//
+// Response content type is checked: it has to be JSON or one of newline
+// delimited JSON media types.
+func DecodeJSONStream(ctx context.Context, resp *http.Response, callback func(decode func(v interface{}) error) error) error {
+	stream := NewJSONStream(ctx, resp)
+	defer stream.Close()
+	if err := checkJSONContentType(resp); err != nil {
+		return err
+	}
+	for stream.Next() {
+		if err := callback(stream.Decode); err != nil {
+			if errors.Is(err, ErrStopDecoding) {
+				return nil
+			}
+			return err
+		}
+	}
+	return stream.Err()
+}
+
+// checkJSONContentType returns error if response content type is set and it
+// is not one of known JSON (or JSON stream) media types.
+func checkJSONContentType(resp *http.Response) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return err
+	}
+	if mediaType == "application/json" || ndjsonMediaTypes[mediaType] {
+		return nil
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+	return fmt.Errorf("cliware: unexpected content type for JSON stream: %s", mediaType)
+}
+
+// ndjsonMediaTypes are media types of newline delimited JSON.
+var ndjsonMediaTypes = map[string]bool{
+	"application/x-ndjson":    true,
+	"application/ndjson":      true,
+	"application/jsonl":       true,
+	"application/x-jsonlines": true,
+}
+
+// isNDJSON checks if response content type is newline delimited JSON.
+func isNDJSON(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && ndjsonMediaTypes[mediaType]
+}
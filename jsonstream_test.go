@@ -0,0 +1,179 @@
+package cliware_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+type streamItem struct {
+	ID int `json:"id"`
+}
+
+// trackingBody is response body that records if it was closed.
+type trackingBody struct {
+	*strings.Reader
+	closed bool
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func jsonResponse(contentType, body string) (*http.Response, *trackingBody) {
+	tracking := &trackingBody{Reader: strings.NewReader(body)}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       tracking,
+	}, tracking
+}
+
+func decodeIDs(t *testing.T, contentType, body string) []int {
+	resp, tracking := jsonResponse(contentType, body)
+	var ids []int
+	err := c.DecodeJSONStream(context.Background(), resp, func(decode func(v interface{}) error) error {
+		var item streamItem
+		if err := decode(&item); err != nil {
+			return err
+		}
+		ids = append(ids, item.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("DecodeJSONStream returned error: ", err)
+	}
+	if !tracking.closed {
+		t.Error("Response body not closed.")
+	}
+	return ids
+}
+
+func TestDecodeJSONStreamNDJSON(t *testing.T) {
+	ids := decodeIDs(t, "application/x-ndjson", "{\"id\": 1}\n{\"id\": 2}\n\n{\"id\": 3}\n")
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("Wrong decoded items: %v", ids)
+	}
+}
+
+func TestDecodeJSONStreamNDJSONArrays(t *testing.T) {
+	resp, _ := jsonResponse("application/x-ndjson", "[1,2]\n[3,4]\n[5,6]\n")
+	var lines [][]int
+	err := c.DecodeJSONStream(context.Background(), resp, func(decode func(v interface{}) error) error {
+		var line []int
+		if err := decode(&line); err != nil {
+			return err
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatal("DecodeJSONStream returned error: ", err)
+	}
+	if len(lines) != 3 || lines[2][1] != 6 {
+		t.Errorf("Wrong decoded lines: %v", lines)
+	}
+}
+
+func TestDecodeJSONStreamArray(t *testing.T) {
+	ids := decodeIDs(t, "application/json; charset=utf-8", "  [{\"id\": 1}, {\"id\": 2}]\n")
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("Wrong decoded items: %v", ids)
+	}
+	if ids := decodeIDs(t, "application/json", "[]"); len(ids) != 0 {
+		t.Errorf("Expected no items, got: %v", ids)
+	}
+}
+
+func TestDecodeJSONStreamStop(t *testing.T) {
+	resp, tracking := jsonResponse("application/x-ndjson", "{\"id\": 1}\n{\"id\": 2}\n")
+	var calls int
+	err := c.DecodeJSONStream(context.Background(), resp, func(decode func(v interface{}) error) error {
+		calls++
+		return c.ErrStopDecoding
+	})
+	if err != nil {
+		t.Error("Expected no error when stopped, got: ", err)
+	}
+
+	resp, _ = jsonResponse("application/x-ndjson", "{\"id\": 1}\n")
+	err = c.DecodeJSONStream(context.Background(), resp, func(decode func(v interface{}) error) error {
+		return fmt.Errorf("enough: %w", c.ErrStopDecoding)
+	})
+	if err != nil {
+		t.Error("Expected no error when stopped with wrapped error, got: ", err)
+	}
+	if calls != 1 || !tracking.closed {
+		t.Errorf("Expected single call and closed body, got %d calls, closed: %t", calls, tracking.closed)
+	}
+
+	myErr := errors.New("custom error")
+	resp, _ = jsonResponse("application/x-ndjson", "{\"id\": 1}\n")
+	err = c.DecodeJSONStream(context.Background(), resp, func(decode func(v interface{}) error) error {
+		return myErr
+	})
+	if err != myErr {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", myErr, err)
+	}
+}
+
+func TestDecodeJSONStreamErrors(t *testing.T) {
+	resp, _ := jsonResponse("text/html", "[]")
+	if err := c.DecodeJSONStream(context.Background(), resp, nil); err == nil {
+		t.Error("Expected error for HTML response.")
+	}
+
+	resp, _ = jsonResponse("application/json", "[{\"id\": 1}, {\"id\": ")
+	stream := c.NewJSONStream(context.Background(), resp)
+	for stream.Next() {
+	}
+	if stream.Err() == nil {
+		t.Error("Expected error for truncated stream.")
+	}
+
+	resp, _ = jsonResponse("application/json", "[{\"id\": 1}]\n{\"id\": 2}")
+	stream = c.NewJSONStream(context.Background(), resp)
+	for stream.Next() {
+	}
+	if stream.Err() == nil {
+		t.Error("Expected error for data after array.")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, _ = jsonResponse("application/json", "[{\"id\": 1}]")
+	err := c.DecodeJSONStream(ctx, resp, func(decode func(v interface{}) error) error {
+		t.Error("Callback called with canceled context.")
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.Canceled, err)
+	}
+}
+
+func TestJSONStreamSkipsUndecoded(t *testing.T) {
+	resp, _ := jsonResponse("application/json", "[{\"id\": 1}, {\"id\": 2}]")
+	resp.Body = ioutil.NopCloser(resp.Body)
+	stream := c.NewJSONStream(context.Background(), resp)
+	defer stream.Close()
+	var count int
+	var last streamItem
+	for stream.Next() {
+		count++
+		if count == 2 {
+			if err := stream.Decode(&last); err != nil {
+				t.Fatal("Decode returned error: ", err)
+			}
+		}
+	}
+	if stream.Err() != nil || count != 2 || last.ID != 2 {
+		t.Errorf("Unexpected result. Count: %d, last: %+v, err: %v", count, last, stream.Err())
+	}
+}
@@ -0,0 +1,60 @@
+package cliware
+
+import (
+	"context"
+	"net/http"
+)
+
+// overridesKey is context key for per request middleware overrides.
+type overridesKey struct{}
+
+// overrides holds middleware changes for single request.
+type overrides struct {
+	extra    []Middleware
+	disabled map[string]bool
+}
+
+// WithMiddlewares returns context that makes Chain execute provided
+// middlewares for request with that context, in addition to its own ones.
+// Extra middlewares run after all chain middlewares (including parent ones),
+// right before final handler, so they see request in its final form. If
+// chains are nested, extra middlewares are executed only once, by outermost
+// chain.
+func WithMiddlewares(ctx context.Context, m ...Middleware) context.Context {
+	o := copyOverrides(ctx)
+	o.extra = append(o.extra, m...)
+	return context.WithValue(ctx, overridesKey{}, o)
+}
+
+// WithoutMiddlewares returns context that makes Chain skip middlewares with
+// provided names (see Named) for request with that context. Names are
+// honored by all chains request passes through, including parent chains.
+func WithoutMiddlewares(ctx context.Context, names ...string) context.Context {
+	o := copyOverrides(ctx)
+	for _, name := range names {
+		o.disabled[name] = true
+	}
+	return context.WithValue(ctx, overridesKey{}, o)
+}
+
+// copyOverrides returns copy of overrides from provided context, so it can
+// be changed without affecting parent context.
+func copyOverrides(ctx context.Context) *overrides {
+	o := &overrides{disabled: make(map[string]bool)}
+	if existing, ok := ctx.Value(overridesKey{}).(*overrides); ok {
+		o.extra = append(o.extra, existing.extra...)
+		for name := range existing.disabled {
+			o.disabled[name] = true
+		}
+	}
+	return o
+}
+
+// overridesFromRequest returns overrides attached to request context, if any.
+func overridesFromRequest(req *http.Request) *overrides {
+	if req == nil {
+		return nil
+	}
+	o, _ := req.Context().Value(overridesKey{}).(*overrides)
+	return o
+}
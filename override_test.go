@@ -0,0 +1,60 @@
+package cliware_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestWithoutMiddlewares(t *testing.T) {
+	var applied []string
+	parent := c.NewChain(c.Named("auth", tag("auth", &applied)), tag("log", &applied))
+	chain := parent.ChildChain(c.Named("retry", tag("retry", &applied)))
+	handler := chain.Exec(c.HandlerFunc(nilHandler))
+
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if expected := []string{"auth", "log", "retry"}; !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected %v to be applied, got %v", expected, applied)
+	}
+
+	applied = nil
+	req = req.WithContext(c.WithoutMiddlewares(req.Context(), "retry", "auth"))
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if expected := []string{"log"}; !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected %v to be applied, got %v", expected, applied)
+	}
+}
+
+func TestWithMiddlewares(t *testing.T) {
+	var applied []string
+	inner := c.NewChain(tag("inner", &applied))
+	chain := c.NewChain(tag("outer", &applied), inner)
+	handler := chain.Exec(c.HandlerFunc(nilHandler))
+
+	ctx := c.WithMiddlewares(context.Background(), tag("extra1", &applied))
+	ctx = c.WithMiddlewares(ctx, tag("extra2", &applied))
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := handler.Handle(req.WithContext(ctx)); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if expected := []string{"outer", "inner", "extra1", "extra2"}; !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected %v to be applied, got %v", expected, applied)
+	}
+
+	// overrides are only for single request
+	applied = nil
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if expected := []string{"outer", "inner"}; !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected %v to be applied, got %v", expected, applied)
+	}
+}
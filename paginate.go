@@ -0,0 +1,314 @@
+package cliware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// NextPageFunc decides how next page is requested. It receives request that
+// was sent and response obtained for it (whose body is buffered, so it can be
+// read regardless of whether caller already read it) and returns request for
+// next page, or nil if there are no more pages.
+type NextPageFunc func(req *http.Request, resp *http.Response) (*http.Request, error)
+
+// Paginator repeatedly sends requests using provided Handler (usually one
+// returned from Chain.Exec, so each page goes through all middlewares) and
+// follows pages as decided by NextPageFunc.
+//
+// Paginator is used as iterator:
+//
+//	pages := NewPaginator(chain.Exec(sender), req, LinkNext)
+//	for pages.Next() {
+//		resp := pages.Response()
+//		...
+//	}
+//	if err := pages.Err(); err != nil {
+//		...
+//	}
+//
+// Body of each page is read into memory, so NextPageFunc can inspect it
+// (e.g. for cursor) and caller can still read it. Body of previous page is
+// closed when Next is called.
+type Paginator struct {
+	// MaxPages is maximal number of pages that will be fetched. Zero means
+	// no limit.
+	MaxPages int
+
+	handler Handler
+	next    NextPageFunc
+	req     *http.Request
+	resp    *http.Response
+	pages   int
+	err     error
+}
+
+// NewPaginator creates Paginator that starts with provided request. Request
+// context controls entire pagination.
+func NewPaginator(handler Handler, req *http.Request, next NextPageFunc) *Paginator {
+	return &Paginator{
+		handler: handler,
+		next:    next,
+		req:     req,
+	}
+}
+
+// Next fetches next page and returns true if it was fetched successfully.
+// It returns false when there are no more pages, page limit has been
+// reached, request context is done or error occurred. Use Err to check for
+// error.
+func (p *Paginator) Next() bool {
+	if p.err != nil || p.req == nil {
+		return false
+	}
+	if p.MaxPages > 0 && p.pages >= p.MaxPages {
+		return false
+	}
+	if p.resp != nil {
+		rewindResponse(p.resp)
+		req, err := p.next(p.req, p.resp)
+		_ = p.resp.Body.Close()
+		p.resp = nil
+		if err != nil || req == nil {
+			p.err = err
+			p.req = nil
+			return false
+		}
+		p.req = req
+	}
+	if err := p.req.Context().Err(); err != nil {
+		p.err = err
+		return false
+	}
+
+	resp, err := p.handler.Handle(p.req)
+	if err != nil {
+		DrainBody(resp)
+		p.err = err
+		return false
+	}
+	if resp.Body != nil {
+		data, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			p.err = err
+			return false
+		}
+		resp.Body = &replayableBody{Reader: bytes.NewReader(data)}
+	} else {
+		resp.Body = &replayableBody{Reader: bytes.NewReader(nil)}
+	}
+	p.resp = resp
+	p.pages++
+	return true
+}
+
+// Response returns response for current page.
+func (p *Paginator) Response() *http.Response {
+	return p.resp
+}
+
+// Page returns number of current page, starting from 1.
+func (p *Paginator) Page() int {
+	return p.pages
+}
+
+// Err returns error that stopped pagination, if any.
+func (p *Paginator) Err() error {
+	return p.err
+}
+
+// Decode decodes JSON body of current page into provided value.
+func (p *Paginator) Decode(v interface{}) error {
+	rewindResponse(p.resp)
+	return json.NewDecoder(p.resp.Body).Decode(v)
+}
+
+// replayableBody is in memory response body that can be read again.
+type replayableBody struct {
+	*bytes.Reader
+}
+
+// Close is implementation of io.Closer interface.
+func (replayableBody) Close() error {
+	return nil
+}
+
+// rewindResponse makes sure response body is read from start again.
+func rewindResponse(resp *http.Response) {
+	if body, ok := resp.Body.(*replayableBody); ok {
+		_, _ = body.Seek(0, 0)
+	}
+}
+
+// LinkNext is NextPageFunc that follows RFC 8288 Link header with
+// rel="next". Relative links are resolved against URL of current request.
+func LinkNext(req *http.Request, resp *http.Response) (*http.Request, error) {
+	for _, header := range resp.Header["Link"] {
+		for _, link := range parseLinks(header) {
+			if !link.hasRel("next") {
+				continue
+			}
+			u, err := req.URL.Parse(link.target)
+			if err != nil {
+				return nil, err
+			}
+			next, err := CloneRequest(req.Context(), req)
+			if err != nil {
+				return nil, err
+			}
+			next.URL = u
+			next.Host = ""
+			return next, nil
+		}
+	}
+	return nil, nil
+}
+
+// CursorNext returns NextPageFunc for cursor based pagination. Cursor is
+// extracted from response by provided function and sent as provided query
+// parameter in next request. Pagination stops when cursor is empty.
+func CursorNext(param string, cursor func(resp *http.Response) (string, error)) NextPageFunc {
+	return func(req *http.Request, resp *http.Response) (*http.Request, error) {
+		value, err := cursor(resp)
+		if err != nil || value == "" {
+			return nil, err
+		}
+		next, err := CloneRequest(req.Context(), req)
+		if err != nil {
+			return nil, err
+		}
+		u := *req.URL
+		query := u.Query()
+		query.Set(param, value)
+		u.RawQuery = query.Encode()
+		next.URL = &u
+		return next, nil
+	}
+}
+
+// HeaderCursor returns cursor function for CursorNext that reads cursor from
+// provided response header.
+func HeaderCursor(header string) func(resp *http.Response) (string, error) {
+	return func(resp *http.Response) (string, error) {
+		return resp.Header.Get(header), nil
+	}
+}
+
+// JSONCursor returns cursor function for CursorNext that reads cursor from
+// JSON response body. Path is list of object keys leading to cursor value,
+// e.g. JSONCursor("meta", "next_cursor"). Numeric cursors are converted to
+// strings, missing or null cursor means there are no more pages.
+func JSONCursor(path ...string) func(resp *http.Response) (string, error) {
+	return func(resp *http.Response) (string, error) {
+		var value interface{}
+		decoder := json.NewDecoder(resp.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return "", err
+		}
+		for _, key := range path {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return "", nil
+			}
+			value = object[key]
+		}
+		switch v := value.(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		}
+		return "", fmt.Errorf("cliware: unexpected cursor value: %v", value)
+	}
+}
+
+// link is single link from Link header.
+type link struct {
+	target string
+	params map[string]string
+}
+
+// hasRel checks if link has provided relation type. Rel parameter can hold
+// multiple space separated relation types.
+func (l link) hasRel(rel string) bool {
+	for _, r := range strings.Fields(l.params["rel"]) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLinks parses value of Link header as defined by RFC 8288.
+func parseLinks(header string) []link {
+	var links []link
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if !strings.HasPrefix(header, "<") {
+			break
+		}
+		end := strings.IndexByte(header, '>')
+		if end < 0 {
+			break
+		}
+		l := link{target: header[1:end], params: make(map[string]string)}
+		header = header[end+1:]
+
+		for {
+			header = strings.TrimLeft(header, " \t")
+			if !strings.HasPrefix(header, ";") {
+				break
+			}
+			header = strings.TrimLeft(header[1:], " \t")
+			nameEnd := strings.IndexAny(header, "=;, \t")
+			if nameEnd < 0 {
+				nameEnd = len(header)
+			}
+			name := strings.ToLower(header[:nameEnd])
+			header = strings.TrimLeft(header[nameEnd:], " \t")
+			var value string
+			if strings.HasPrefix(header, "=") {
+				value, header = parseParamValue(strings.TrimLeft(header[1:], " \t"))
+			}
+			if _, exists := l.params[name]; !exists {
+				l.params[name] = value
+			}
+		}
+		links = append(links, l)
+	}
+	return links
+}
+
+// parseParamValue parses token or quoted string at start of provided string
+// and returns it together with rest of the string.
+func parseParamValue(s string) (value, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, ";, \t")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:]
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
@@ -0,0 +1,107 @@
+package cliware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestPaginatorLinkHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			w.Header().Set("Link", `</items?page=2>; rel="next", </items?page=9>; rel=last`)
+		case "2":
+			w.Header().Add("Link", `<https://other.example.com/>; rel="prev"`)
+			w.Header().Add("Link", `</items?page=3>; title="a, b"; rel="last next"`)
+		}
+		fmt.Fprintf(w, `{"page": %q}`, page)
+	}))
+	defer server.Close()
+
+	var requests int
+	chain := c.NewChain(c.RequestProcessor(func(req *http.Request) error {
+		requests++
+		return nil
+	}))
+	req, _ := http.NewRequest("GET", server.URL+"/items", nil)
+	pages := c.NewPaginator(chain.Exec(c.HandlerFunc(sender)), req, c.LinkNext)
+	var got []string
+	for pages.Next() {
+		var body struct {
+			Page string `json:"page"`
+		}
+		if err := pages.Decode(&body); err != nil {
+			t.Fatal("Decode returned error: ", err)
+		}
+		got = append(got, body.Page)
+	}
+	if pages.Err() != nil {
+		t.Fatal("Paginator returned error: ", pages.Err())
+	}
+	if fmt.Sprint(got) != "[ 2 3]" {
+		t.Errorf("Wrong pages: %q", got)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests through chain, got: %d", requests)
+	}
+}
+
+func TestPaginatorJSONCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			fmt.Fprint(w, `{"meta": {"next": "abc"}}`)
+		case "abc":
+			fmt.Fprint(w, `{"meta": {"next": 42}}`)
+		default:
+			fmt.Fprint(w, `{"meta": {"next": null}}`)
+		}
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	pages := c.NewPaginator(c.HandlerFunc(sender), req, c.CursorNext("cursor", c.JSONCursor("meta", "next")))
+	var cursors []string
+	for pages.Next() {
+		cursors = append(cursors, pages.Response().Request.URL.Query().Get("cursor"))
+	}
+	if pages.Err() != nil {
+		t.Fatal("Paginator returned error: ", pages.Err())
+	}
+	if fmt.Sprint(cursors) != "[ abc 42]" {
+		t.Errorf("Wrong cursors: %q", cursors)
+	}
+}
+
+func TestPaginatorLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Next", "more")
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	pages := c.NewPaginator(c.HandlerFunc(sender), req, c.CursorNext("cursor", c.HeaderCursor("X-Next")))
+	pages.MaxPages = 3
+	for pages.Next() {
+	}
+	if pages.Err() != nil || pages.Page() != 3 {
+		t.Errorf("Expected 3 pages without error, got %d pages and error: %v", pages.Page(), pages.Err())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages = c.NewPaginator(c.HandlerFunc(sender), req.WithContext(ctx), c.CursorNext("cursor", c.HeaderCursor("X-Next")))
+	pages.Next()
+	cancel()
+	if pages.Next() {
+		t.Error("Expected pagination to stop after context was canceled.")
+	}
+	if pages.Err() != context.Canceled {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.Canceled, pages.Err())
+	}
+}
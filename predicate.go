@@ -0,0 +1,128 @@
+package cliware
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// RequestPredicate decides if request satisfies some condition.
+type RequestPredicate func(req *http.Request) bool
+
+// When returns Middleware that applies provided middleware only to requests
+// that satisfy predicate. Other requests are passed directly to next
+// handler.
+func When(predicate RequestPredicate, m Middleware) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		wrapped := m.Exec(next)
+		return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+			if predicate(req) {
+				return wrapped.Handle(req)
+			}
+			return next.Handle(req)
+		})
+	})
+}
+
+// Unless returns Middleware that applies provided middleware only to
+// requests that do not satisfy predicate.
+func Unless(predicate RequestPredicate, m Middleware) Middleware {
+	return When(Not(predicate), m)
+}
+
+// Not returns predicate that negates provided one.
+func Not(predicate RequestPredicate) RequestPredicate {
+	return func(req *http.Request) bool {
+		return !predicate(req)
+	}
+}
+
+// All returns predicate satisfied when all provided predicates are.
+func All(predicates ...RequestPredicate) RequestPredicate {
+	return func(req *http.Request) bool {
+		for _, p := range predicates {
+			if !p(req) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any returns predicate satisfied when at least one of provided predicates
+// is.
+func Any(predicates ...RequestPredicate) RequestPredicate {
+	return func(req *http.Request) bool {
+		for _, p := range predicates {
+			if p(req) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HostGlob returns predicate satisfied by requests whose host (without
+// port) matches one of provided glob patterns, as used by path.Match, e.g.
+// "*.internal.example.com".
+func HostGlob(patterns ...string) RequestPredicate {
+	return func(req *http.Request) bool {
+		host := HostKey(req)
+		if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+			host = host[:i]
+		}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, host); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// PathPrefix returns predicate satisfied by requests whose URL path starts
+// with provided prefix.
+func PathPrefix(prefix string) RequestPredicate {
+	return func(req *http.Request) bool {
+		return req.URL != nil && strings.HasPrefix(req.URL.Path, prefix)
+	}
+}
+
+// Methods returns predicate satisfied by requests with one of provided HTTP
+// methods.
+func Methods(methods ...string) RequestPredicate {
+	return func(req *http.Request) bool {
+		method := req.Method
+		if method == "" {
+			method = "GET"
+		}
+		for _, m := range methods {
+			if strings.EqualFold(m, method) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasHeader returns predicate satisfied by requests that have header with
+// provided name.
+func HasHeader(name string) RequestPredicate {
+	return func(req *http.Request) bool {
+		_, ok := req.Header[http.CanonicalHeaderKey(name)]
+		return ok
+	}
+}
+
+// ContextValue returns predicate satisfied by requests whose context holds
+// provided value under provided key. If value is nil, any non nil value
+// satisfies predicate.
+func ContextValue(key, value interface{}) RequestPredicate {
+	return func(req *http.Request) bool {
+		v := req.Context().Value(key)
+		if value == nil {
+			return v != nil
+		}
+		return v == value
+	}
+}
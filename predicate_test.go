@@ -0,0 +1,70 @@
+package cliware_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestWhenAndUnless(t *testing.T) {
+	var applied []string
+	header := func(name string) c.Middleware {
+		return c.RequestProcessor(func(req *http.Request) error {
+			applied = append(applied, name)
+			return nil
+		})
+	}
+	internal := c.HostGlob("*.internal")
+	chain := c.NewChain(
+		c.When(internal, header("auth")),
+		c.Unless(internal, header("public")),
+	)
+	handler, handlerCalled := createHandler()
+
+	req, _ := http.NewRequest("GET", "http://api.internal:8080/", nil)
+	chain.Exec(handler).Handle(req)
+	req, _ = http.NewRequest("GET", "http://example.com/", nil)
+	chain.Exec(handler).Handle(req)
+	if len(applied) != 2 || applied[0] != "auth" || applied[1] != "public" {
+		t.Errorf("Wrong middlewares applied: %v", applied)
+	}
+	if !*handlerCalled {
+		t.Error("Final handler not called.")
+	}
+}
+
+type ctxKey struct{}
+
+func TestPredicates(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://api.example.com/v1/users", nil)
+	req.Header.Set("X-Trace", "")
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "value"))
+
+	cases := []struct {
+		name      string
+		predicate c.RequestPredicate
+		expected  bool
+	}{
+		{"host glob", c.HostGlob("*.example.com"), true},
+		{"host glob mismatch", c.HostGlob("*.internal"), false},
+		{"path prefix", c.PathPrefix("/v1/"), true},
+		{"path prefix mismatch", c.PathPrefix("/v2/"), false},
+		{"methods", c.Methods("get", "post"), true},
+		{"methods mismatch", c.Methods("GET"), false},
+		{"header", c.HasHeader("x-trace"), true},
+		{"header mismatch", c.HasHeader("X-Other"), false},
+		{"context value", c.ContextValue(ctxKey{}, "value"), true},
+		{"context any value", c.ContextValue(ctxKey{}, nil), true},
+		{"context value mismatch", c.ContextValue(ctxKey{}, "other"), false},
+		{"all", c.All(c.Methods("POST"), c.PathPrefix("/v1")), true},
+		{"any", c.Any(c.Methods("GET"), c.PathPrefix("/v1")), true},
+		{"not", c.Not(c.Methods("POST")), false},
+	}
+	for _, tc := range cases {
+		if tc.predicate(req) != tc.expected {
+			t.Errorf("Predicate %q returned %t, expected %t", tc.name, !tc.expected, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package cliware
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProgressDirection tells if progress is reported for request or response
+// body.
+type ProgressDirection int
+
+const (
+	// Upload is progress of sending request body.
+	Upload ProgressDirection = iota
+	// Download is progress of reading response body.
+	Download
+)
+
+// String returns human readable name of direction.
+func (d ProgressDirection) String() string {
+	if d == Upload {
+		return "upload"
+	}
+	return "download"
+}
+
+// Progress holds information about transfer of single request or response
+// body.
+type Progress struct {
+	// Request is request whose body (or response body) is transferred.
+	Request *http.Request
+	// Direction tells if this is upload or download progress.
+	Direction ProgressDirection
+	// Bytes is number of bytes transferred so far.
+	Bytes int64
+	// Total is total number of bytes, or -1 if it is not known.
+	Total int64
+	// Rate is average transfer rate in bytes per second.
+	Rate float64
+	// ETA is estimated time until transfer is done, or -1 if it can not be
+	// estimated.
+	ETA time.Duration
+	// Done is true for last report, when body has been read completely,
+	// reading failed or body was closed.
+	Done bool
+	// Err is error that stopped transfer, if any.
+	Err error
+}
+
+// ReportProgress returns Middleware that reports progress of request and
+// response body transfer to provided callback, at most once per interval
+// (and once more when transfer is done).
+//
+// Bytes are counted where middleware is placed in chain. To report progress
+// of bytes actually transferred over network, place it after (closer to final
+// handler than) middlewares that compress or decompress bodies. Total size
+// is taken from ContentLength and is not known if response was transparently
+// decompressed by http.Transport.
+func ReportProgress(interval time.Duration, callback func(p Progress)) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+			if req.Body != nil && req.Body != http.NoBody {
+				total := req.ContentLength
+				if total <= 0 {
+					total = -1
+				}
+				req.Body = newProgressReader(req.Body, Progress{Request: req, Direction: Upload, Total: total}, interval, callback)
+				if getBody := req.GetBody; getBody != nil {
+					req.GetBody = func() (io.ReadCloser, error) {
+						body, err := getBody()
+						if err != nil {
+							return nil, err
+						}
+						return newProgressReader(body, Progress{Request: req, Direction: Upload, Total: total}, interval, callback), nil
+					}
+				}
+			}
+
+			resp, err = next.Handle(req)
+			if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+				total := resp.ContentLength
+				if total < 0 || resp.Uncompressed {
+					total = -1
+				}
+				resp.Body = newProgressReader(resp.Body, Progress{Request: req, Direction: Download, Total: total}, interval, callback)
+			}
+			return resp, err
+		})
+	})
+}
+
+// progressReader is body wrapper that counts read bytes and reports
+// progress.
+type progressReader struct {
+	body     io.ReadCloser
+	interval time.Duration
+	callback func(p Progress)
+
+	mu         sync.Mutex
+	progress   Progress
+	start      time.Time
+	lastReport time.Time
+	done       bool
+}
+
+func newProgressReader(body io.ReadCloser, progress Progress, interval time.Duration, callback func(p Progress)) *progressReader {
+	now := time.Now()
+	return &progressReader{
+		body:       body,
+		interval:   interval,
+		callback:   callback,
+		progress:   progress,
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// Read is implementation of io.Reader interface.
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.mu.Lock()
+	r.progress.Bytes += int64(n)
+	now := time.Now()
+	switch {
+	case err == io.EOF:
+		r.report(now, nil)
+	case err != nil:
+		r.report(now, err)
+	case now.Sub(r.lastReport) >= r.interval:
+		r.lastReport = now
+		r.callback(r.snapshot(now))
+	}
+	r.mu.Unlock()
+	return n, err
+}
+
+// Close is implementation of io.Closer interface.
+func (r *progressReader) Close() error {
+	err := r.body.Close()
+	r.mu.Lock()
+	r.report(time.Now(), nil)
+	r.mu.Unlock()
+	return err
+}
+
+// report sends final report, if it was not already sent.
+func (r *progressReader) report(now time.Time, err error) {
+	if r.done {
+		return
+	}
+	r.done = true
+	progress := r.snapshot(now)
+	progress.Done = true
+	progress.Err = err
+	r.callback(progress)
+}
+
+// snapshot returns current progress with calculated rate and ETA.
+func (r *progressReader) snapshot(now time.Time) Progress {
+	progress := r.progress
+	progress.ETA = -1
+	if elapsed := now.Sub(r.start).Seconds(); elapsed > 0 {
+		progress.Rate = float64(progress.Bytes) / elapsed
+	}
+	if progress.Total >= 0 && progress.Rate > 0 {
+		remaining := progress.Total - progress.Bytes
+		if remaining < 0 {
+			remaining = 0
+		}
+		progress.ETA = time.Duration(float64(remaining) / progress.Rate * float64(time.Second))
+	}
+	return progress
+}
@@ -0,0 +1,121 @@
+package cliware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestReportProgressDownload(t *testing.T) {
+	var reports []c.Progress
+	progress := c.ReportProgress(0, func(p c.Progress) {
+		reports = append(reports, p)
+	})
+	handler := progress.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			ContentLength: 10,
+			Body:          ioutil.NopCloser(strings.NewReader("0123456789")),
+			Request:       req,
+		}, nil
+	}))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	buf := make([]byte, 4)
+	for {
+		if _, err := resp.Body.Read(buf); err != nil {
+			break
+		}
+	}
+	resp.Body.Close()
+
+	if len(reports) == 0 {
+		t.Fatal("No progress reported.")
+	}
+	last := reports[len(reports)-1]
+	if !last.Done || last.Bytes != 10 || last.Total != 10 || last.Direction != c.Download {
+		t.Errorf("Wrong final report: %+v", last)
+	}
+	for _, r := range reports[:len(reports)-1] {
+		if r.Done {
+			t.Error("Only last report should be marked as done.")
+		}
+	}
+}
+
+func TestReportProgressUpload(t *testing.T) {
+	var last c.Progress
+	progress := c.ReportProgress(0, func(p c.Progress) {
+		last = p
+	})
+	handler := progress.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		_, err := ioutil.ReadAll(req.Body)
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, err
+	}))
+	req, _ := http.NewRequest("POST", "http://localhost", strings.NewReader("payload"))
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if !last.Done || last.Direction != c.Upload || last.Bytes != 7 || last.Total != 7 {
+		t.Errorf("Wrong final report: %+v", last)
+	}
+}
+
+// gunzip is middleware that decompresses gzip encoded responses.
+func gunzip(next c.Handler) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.Handle(req)
+		if err != nil || resp.Header.Get("Content-Encoding") != "gzip" {
+			return resp, err
+		}
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return resp, err
+		}
+		resp.Body = ioutil.NopCloser(reader)
+		resp.Header.Del("Content-Encoding")
+		resp.ContentLength = -1
+		return resp, nil
+	})
+}
+
+func TestReportProgressWithCompression(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(bytes.Repeat([]byte("a"), 1000))
+	w.Close()
+	size := int64(compressed.Len())
+
+	var last c.Progress
+	chain := c.NewChain(c.MiddlewareFunc(gunzip), c.ReportProgress(0, func(p c.Progress) {
+		last = p
+	}))
+	handler := chain.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Encoding": []string{"gzip"}},
+			ContentLength: size,
+			Body:          ioutil.NopCloser(bytes.NewReader(compressed.Bytes())),
+			Request:       req,
+		}, nil
+	}))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 1000 {
+		t.Errorf("Wrong decompressed body length: %d", len(body))
+	}
+	if !last.Done || last.Bytes != size || last.Total != size {
+		t.Errorf("Expected progress of %d compressed bytes, got: %+v", size, last)
+	}
+}
@@ -0,0 +1,102 @@
+package cliware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is error returned by Recover middleware when panic occurs in
+// the rest of the chain.
+type PanicError struct {
+	// Value is value panic was called with.
+	Value interface{}
+	// Stack is stack trace of goroutine at the moment of panic.
+	Stack []byte
+}
+
+// Error is implementation of error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("cliware: panic: %v", e.Value)
+}
+
+// Unwrap returns panic value if it is error, so errors.Is and errors.As can
+// be used on panics with error values.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover returns Middleware that recovers from panics in the rest of the
+// chain (middlewares after it and final handler) and returns *PanicError
+// instead. If hook is not nil, it is called with request and error for each
+// recovered panic.
+//
+// Bodies of responses that were obtained before panic are closed. Responses
+// returned by final handler of Chain are tracked, so this works for panics
+// in any middleware as long as Recover is used in Chain. If Recover is used
+// with other handler, responses can not be tracked.
+func Recover(hook func(req *http.Request, err *PanicError)) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+			tracker := &responseTracker{}
+			if req != nil {
+				req = req.WithContext(context.WithValue(req.Context(), responseTrackerKey{}, tracker))
+			}
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				tracker.closeAll()
+				panicErr := &PanicError{Value: r, Stack: debug.Stack()}
+				if hook != nil {
+					hook(req, panicErr)
+				}
+				resp, err = nil, panicErr
+			}()
+			return next.Handle(req)
+		})
+	})
+}
+
+// responseTrackerKey is context key for responseTracker.
+type responseTrackerKey struct{}
+
+// responseTracker holds responses obtained while request is processed, so
+// their bodies can be closed if processing panics.
+type responseTracker struct {
+	mu        sync.Mutex
+	responses []*http.Response
+}
+
+// closeAll closes bodies of all tracked responses.
+func (t *responseTracker) closeAll() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, resp := range t.responses {
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+	}
+	t.responses = nil
+}
+
+// trackResponses returns handler that records responses returned by
+// provided final handler for requests processed by Recover middleware.
+func trackResponses(handler Handler) Handler {
+	return HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := handler.Handle(req)
+		if req == nil || resp == nil {
+			return resp, err
+		}
+		if tracker, ok := req.Context().Value(responseTrackerKey{}).(*responseTracker); ok {
+			tracker.mu.Lock()
+			tracker.responses = append(tracker.responses, resp)
+			tracker.mu.Unlock()
+		}
+		return resp, err
+	})
+}
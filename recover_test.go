@@ -0,0 +1,96 @@
+package cliware_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+// closeTracker is response body that records if it was closed.
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeTracker) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRecoverRequestProcessor(t *testing.T) {
+	var hookErr *c.PanicError
+	chain := c.NewChain(
+		c.Recover(func(req *http.Request, err *c.PanicError) { hookErr = err }),
+		c.RequestProcessor(func(req *http.Request) error { panic("boom") }),
+	)
+	handler, handlerCalled := createHandler()
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := chain.Exec(handler).Handle(req)
+
+	panicErr, ok := err.(*c.PanicError)
+	if !ok {
+		t.Fatalf("Expected *PanicError, got: %v", err)
+	}
+	if panicErr.Value != "boom" || !strings.Contains(string(panicErr.Stack), "recover_test.go") {
+		t.Errorf("Unexpected panic error: %v\n%s", panicErr.Value, panicErr.Stack)
+	}
+	if hookErr != panicErr {
+		t.Error("Hook not called with panic error.")
+	}
+	if *handlerCalled {
+		t.Error("Final handler should not be called.")
+	}
+}
+
+func TestRecoverClosesBody(t *testing.T) {
+	errPanic := errors.New("panic value")
+	body := &closeTracker{Reader: strings.NewReader("body")}
+	chain := c.NewChain(
+		c.Recover(nil),
+		c.ResponseProcessor(func(resp *http.Response, err error) error { panic(errPanic) }),
+	)
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	resp, err := chain.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+	})).Handle(req)
+
+	if resp != nil {
+		t.Error("Expected no response after panic.")
+	}
+	if !errors.Is(err, errPanic) {
+		t.Errorf("Expected error to wrap panic value, got: %v", err)
+	}
+	if !body.closed {
+		t.Error("Response body not closed after panic.")
+	}
+}
+
+func TestRecoverClosesBodyMiddlewareFunc(t *testing.T) {
+	body := &closeTracker{Reader: strings.NewReader("body")}
+	chain := c.NewChain(
+		c.Recover(nil),
+		c.MiddlewareFunc(func(next c.Handler) c.Handler {
+			return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+				if _, err := next.Handle(req); err == nil {
+					panic("got response")
+				}
+				return nil, nil
+			})
+		}),
+	)
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	_, err := chain.Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: body, Request: req}, nil
+	})).Handle(req)
+
+	if _, ok := err.(*c.PanicError); !ok {
+		t.Fatalf("Expected *PanicError, got: %v", err)
+	}
+	if !body.closed {
+		t.Error("Response body not closed after panic.")
+	}
+}
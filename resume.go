@@ -0,0 +1,144 @@
+package cliware
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrRangeNotSupported is returned from response body read by resumable
+// download when server did not honor range request, e.g. because it does
+// not support it or because resource changed in the meantime.
+var ErrRangeNotSupported = errors.New("cliware: server did not resume download from requested offset")
+
+// ResumeDownloads returns Middleware that resumes interrupted downloads.
+// When reading response body fails in the middle, request is sent again
+// through next handler with Range header asking for the rest of the body and
+// If-Range header with ETag (or Last-Modified) of original response, so
+// server sends the rest only if resource did not change. Caller reads single
+// continuous body and does not notice interruption.
+//
+// Only successful (200 OK) responses to GET requests without Range header
+// whose response has validator (ETag or Last-Modified) are resumed, at most
+// maxResumes times. If server responds with anything other than expected
+// partial content, reading fails with ErrRangeNotSupported instead of
+// returning wrong data.
+func ResumeDownloads(maxResumes int) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+			if req.Method != "GET" && req.Method != "" {
+				return next.Handle(req)
+			}
+			if err := RewindableBody(req); err != nil {
+				return nil, err
+			}
+			resp, err = next.Handle(req)
+			if err != nil || resp == nil || resp.Body == nil {
+				return resp, err
+			}
+			if req.Header.Get("Range") != "" || resp.StatusCode != http.StatusOK {
+				return resp, err
+			}
+			validator := resp.Header.Get("ETag")
+			if validator == "" || strings.HasPrefix(validator, "W/") {
+				// weak ETags can not be used in If-Range
+				validator = resp.Header.Get("Last-Modified")
+			}
+			if validator == "" {
+				return resp, err
+			}
+			resp.Body = &resumingBody{
+				next:       next,
+				req:        req,
+				body:       resp.Body,
+				validator:  validator,
+				total:      resp.ContentLength,
+				maxResumes: maxResumes,
+			}
+			return resp, err
+		})
+	})
+}
+
+// resumingBody is response body that reissues request for the rest of the
+// body when reading fails.
+type resumingBody struct {
+	next       Handler
+	req        *http.Request
+	body       io.ReadCloser
+	validator  string
+	total      int64
+	offset     int64
+	resumes    int
+	maxResumes int
+}
+
+// Read is implementation of io.Reader interface.
+func (b *resumingBody) Read(p []byte) (int, error) {
+	for {
+		n, err := b.body.Read(p)
+		b.offset += int64(n)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		if b.resumes >= b.maxResumes || b.req.Context().Err() != nil {
+			return n, err
+		}
+		b.resumes++
+		if resumeErr := b.resume(); resumeErr != nil {
+			if resumeErr == ErrRangeNotSupported {
+				return n, resumeErr
+			}
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close is implementation of io.Closer interface.
+func (b *resumingBody) Close() error {
+	return b.body.Close()
+}
+
+// resume requests rest of the body starting at current offset.
+func (b *resumingBody) resume() error {
+	req, err := CloneRequest(b.req.Context(), b.req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", b.offset))
+	req.Header.Set("If-Range", b.validator)
+
+	resp, err := b.next.Handle(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent || !b.validRange(resp.Header.Get("Content-Range")) {
+		DrainBody(resp)
+		return ErrRangeNotSupported
+	}
+	_ = b.body.Close()
+	b.body = resp.Body
+	return nil
+}
+
+// validRange checks that Content-Range header describes part of the same
+// resource starting at current offset.
+func (b *resumingBody) validRange(contentRange string) bool {
+	var start, end int64
+	var total string
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%s", &start, &end, &total); err != nil {
+		return false
+	}
+	if start != b.offset {
+		return false
+	}
+	if b.total >= 0 && total != "*" && total != fmt.Sprint(b.total) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package cliware_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+// failingReader returns content and then fails with error instead of EOF.
+type failingReader struct {
+	io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+// flakyServer returns handler serving content that breaks after every chunk
+// bytes. If honorRange is false, Range header is ignored.
+func flakyServer(content string, chunk int, honorRange bool, ranges *[]string) c.Handler {
+	return c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		var start int
+		status := http.StatusOK
+		header := http.Header{"Etag": []string{`"v1"`}}
+		if r := req.Header.Get("Range"); r != "" {
+			*ranges = append(*ranges, r+" "+req.Header.Get("If-Range"))
+			if honorRange {
+				fmt.Sscanf(r, "bytes=%d-", &start)
+				status = http.StatusPartialContent
+				header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+			}
+		}
+		end := start + chunk
+		var body io.Reader
+		if end >= len(content) {
+			body = strings.NewReader(content[start:])
+		} else {
+			body = failingReader{strings.NewReader(content[start:end])}
+		}
+		return &http.Response{
+			StatusCode:    status,
+			Header:        header,
+			ContentLength: int64(len(content) - start),
+			Body:          ioutil.NopCloser(body),
+			Request:       req,
+		}, nil
+	})
+}
+
+func TestResumeDownloads(t *testing.T) {
+	var ranges []string
+	handler := c.ResumeDownloads(5).Exec(flakyServer("0123456789", 4, true, &ranges))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("Reading body failed: ", err)
+	}
+	if string(body) != "0123456789" {
+		t.Errorf("Wrong stitched body: %q", body)
+	}
+	if len(ranges) != 2 || ranges[0] != `bytes=4- "v1"` || ranges[1] != `bytes=8- "v1"` {
+		t.Errorf("Wrong resume requests: %v", ranges)
+	}
+}
+
+func TestResumeDownloadsRangeIgnored(t *testing.T) {
+	var ranges []string
+	handler := c.ResumeDownloads(5).Exec(flakyServer("0123456789", 4, false, &ranges))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != c.ErrRangeNotSupported {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrRangeNotSupported, err)
+	}
+	if string(body) != "0123" {
+		t.Errorf("Unexpected data returned: %q", body)
+	}
+}
+
+func TestResumeDownloadsLimit(t *testing.T) {
+	var ranges []string
+	handler := c.ResumeDownloads(1).Exec(flakyServer("0123456789", 4, true, &ranges))
+	resp, err := handler.Handle(c.EmptyRequest())
+	if err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil || string(body) != "01234567" {
+		t.Errorf("Expected error after 1 resume, got body %q and error: %v", body, err)
+	}
+}
+
+func TestResumeDownloadsKeepsStreamingBody(t *testing.T) {
+	body := ioutil.NopCloser(strings.NewReader("data"))
+	handler := c.ResumeDownloads(5).Exec(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != body || req.GetBody != nil {
+			t.Error("Request body of POST request was buffered.")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))
+	req := c.EmptyRequest()
+	req.Method = "POST"
+	req.Body = body
+	if _, err := handler.Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+}
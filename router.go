@@ -0,0 +1,84 @@
+package cliware
+
+import (
+	"net/http"
+	"path"
+)
+
+// Router is Middleware that dispatches each request to one of several
+// middleware chains, based on request host, path, method or custom
+// predicate. Routes are checked in order in which they were added and first
+// one that matches is used. Requests that do not match any route go
+// through default chain, or directly to next handler if there is no
+// default chain.
+//
+// All chains end with the same next handler, so single client can talk to
+// multiple APIs with different authentication, retry and decoding stacks,
+// while callers use single Handler.
+type Router struct {
+	routes   []route
+	fallback *Chain
+}
+
+// route is single Router route.
+type route struct {
+	predicate RequestPredicate
+	chain     *Chain
+}
+
+// NewRouter creates Router with provided default chain, which can be nil.
+func NewRouter(fallback *Chain) *Router {
+	return &Router{
+		fallback: fallback,
+	}
+}
+
+// Route adds route that sends requests satisfying predicate through
+// provided chain.
+func (r *Router) Route(predicate RequestPredicate, chain *Chain) *Router {
+	r.routes = append(r.routes, route{predicate: predicate, chain: chain})
+	return r
+}
+
+// Host adds route for requests whose host matches one of provided glob
+// patterns (see HostGlob).
+func (r *Router) Host(chain *Chain, patterns ...string) *Router {
+	return r.Route(HostGlob(patterns...), chain)
+}
+
+// Path adds route for requests whose URL path matches provided pattern, as
+// used by path.Match, e.g. "/api/v1/*".
+func (r *Router) Path(chain *Chain, pattern string) *Router {
+	return r.Route(func(req *http.Request) bool {
+		if req.URL == nil {
+			return false
+		}
+		ok, _ := path.Match(pattern, req.URL.Path)
+		return ok
+	}, chain)
+}
+
+// Method adds route for requests with one of provided methods.
+func (r *Router) Method(chain *Chain, methods ...string) *Router {
+	return r.Route(Methods(methods...), chain)
+}
+
+// Exec is implementation of Middleware interface.
+func (r *Router) Exec(next Handler) Handler {
+	handlers := make([]Handler, len(r.routes))
+	for i, route := range r.routes {
+		handlers[i] = route.chain.Exec(next)
+	}
+	fallback := next
+	if r.fallback != nil {
+		fallback = r.fallback.Exec(next)
+	}
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		for i, route := range r.routes {
+			if route.predicate(req) {
+				return handlers[i].Handle(req)
+			}
+		}
+		return fallback.Handle(req)
+	})
+}
@@ -0,0 +1,63 @@
+package cliware_test
+
+import (
+	"net/http"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+// tag returns middleware that records provided name when applied.
+func tag(name string, applied *[]string) c.Middleware {
+	return c.RequestProcessor(func(req *http.Request) error {
+		*applied = append(*applied, name)
+		return nil
+	})
+}
+
+func TestRouter(t *testing.T) {
+	var applied []string
+	router := c.NewRouter(c.NewChain(tag("default", &applied))).
+		Host(c.NewChain(tag("github", &applied)), "api.github.com").
+		Path(c.NewChain(tag("v1", &applied)), "/v1/*").
+		Method(c.NewChain(tag("delete", &applied)), "DELETE").
+		Route(c.HasHeader("X-Custom"), c.NewChain(tag("custom", &applied)))
+	handler := c.NewChain(router).Exec(c.HandlerFunc(nilHandler))
+
+	requests := []struct {
+		method, url, header string
+		expected            string
+	}{
+		{"GET", "https://api.github.com/repos", "", "github"},
+		{"GET", "https://example.com/v1/users", "", "v1"},
+		{"DELETE", "https://example.com/users/1", "", "delete"},
+		{"GET", "https://example.com/", "X-Custom", "custom"},
+		{"GET", "https://example.com/v2/users", "", "default"},
+	}
+	for _, r := range requests {
+		applied = nil
+		req, _ := http.NewRequest(r.method, r.url, nil)
+		if r.header != "" {
+			req.Header.Set(r.header, "1")
+		}
+		if _, err := handler.Handle(req); err != nil {
+			t.Fatal("Handle returned error: ", err)
+		}
+		if len(applied) != 1 || applied[0] != r.expected {
+			t.Errorf("%s %s routed to %v, expected %s", r.method, r.url, applied, r.expected)
+		}
+	}
+}
+
+func TestRouterWithoutDefault(t *testing.T) {
+	var applied []string
+	router := c.NewRouter(nil).Host(c.NewChain(tag("github", &applied)), "api.github.com")
+	handler, handlerCalled := createHandler()
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	if _, err := router.Exec(handler).Handle(req); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if len(applied) != 0 || !*handlerCalled {
+		t.Errorf("Expected request to go directly to next handler, applied: %v", applied)
+	}
+}
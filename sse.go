@@ -0,0 +1,309 @@
+package cliware
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEventStreamRetry is reconnection delay used until server sets one.
+const defaultEventStreamRetry = 3 * time.Second
+
+// ErrEventStreamClosed is returned by EventStream.Err if stream was closed
+// by calling Close.
+var ErrEventStreamClosed = errors.New("cliware: event stream closed")
+
+// Event is single Server-Sent Event.
+type Event struct {
+	// ID is value of last id field received, including ones received
+	// with previous events.
+	ID string
+	// Type is event type ("message" if server did not set it).
+	Type string
+	// Data is event payload. Multiple data fields are joined with newline.
+	Data string
+	// Retry is reconnection delay requested by server with this event, or
+	// zero if event did not contain retry field.
+	Retry time.Duration
+}
+
+// EventStream reads Server-Sent Events (text/event-stream) from responses
+// obtained from provided Handler, usually one returned from Chain.Exec.
+//
+// When connection drops or can not be established because of network error,
+// EventStream reconnects after retry delay set by server (or RetryDelay if
+// server did not set one) and sends Last-Event-ID header, so server can
+// continue where it stopped. Other errors returned by handler (e.g. by
+// authentication middleware) stop the stream. Each connection is new
+// request sent through the handler, so all middlewares (authentication,
+// logging, etc.) are applied to each of them.
+//
+// EventStream is used as iterator:
+//
+//	stream := NewEventStream(chain.Exec(sender), req)
+//	defer stream.Close()
+//	for stream.Next() {
+//		event := stream.Event()
+//		...
+//	}
+//	if err := stream.Err(); err != nil {
+//		...
+//	}
+type EventStream struct {
+	// RetryDelay is reconnection delay used until server sends retry field.
+	RetryDelay time.Duration
+
+	// MaxReconnects is maximal number of consecutive reconnects without
+	// receiving any event. Zero means no limit. When limit is reached, error
+	// that caused last reconnect is wrapped in stream error.
+	MaxReconnects int
+
+	handler    Handler
+	req        *http.Request
+	reader     *bufio.Reader
+	event      Event
+	lastID     string
+	reconnects int
+	connected  bool
+	lastErr    error
+	err        error
+
+	// mu guards state shared with Close, which can be called from other
+	// goroutine
+	mu      sync.Mutex
+	body    io.ReadCloser
+	closed  bool
+	closing chan struct{}
+}
+
+// NewEventStream creates EventStream that sends provided request using
+// provided handler. Request context controls lifetime of entire stream,
+// including reconnects. No request is sent until first call to Next.
+func NewEventStream(handler Handler, req *http.Request) *EventStream {
+	return &EventStream{
+		RetryDelay: defaultEventStreamRetry,
+		handler:    handler,
+		req:        req,
+		closing:    make(chan struct{}),
+	}
+}
+
+// Next waits for next event and returns true if one is received. It returns
+// false when stream ends, either because of error, request context being
+// done or Close being called. Use Err to find out why stream ended.
+func (s *EventStream) Next() bool {
+	for s.err == nil {
+		if s.isClosed() {
+			s.err = ErrEventStreamClosed
+			break
+		}
+		if s.reader == nil {
+			if err := s.connect(); err != nil {
+				s.err = err
+				break
+			}
+			if s.reader == nil {
+				continue
+			}
+		}
+		event, err := s.readEvent()
+		if err == nil {
+			s.event = event
+			s.reconnects = 0
+			s.lastErr = nil
+			return true
+		}
+		s.lastErr = err
+		s.disconnect()
+	}
+	return false
+}
+
+// Event returns event received by last call to Next.
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// LastEventID returns ID of last received event. It is sent to server as
+// Last-Event-ID header on reconnect.
+func (s *EventStream) LastEventID() string {
+	return s.lastID
+}
+
+// Err returns error that stopped the stream or nil if stream is still
+// active.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close stops the stream and closes response body, if any. It can be called
+// from other goroutine to stop Next that waits for event. Request that is
+// being sent is not interrupted, use request context for that.
+func (s *EventStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.closing)
+	if s.body != nil {
+		return s.body.Close()
+	}
+	return nil
+}
+
+// isClosed checks if Close has been called.
+func (s *EventStream) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// disconnect closes current connection.
+func (s *EventStream) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.body != nil {
+		_ = s.body.Close()
+		s.body = nil
+	}
+	s.reader = nil
+}
+
+// connect sends request for the stream. If sending request fails with error
+// that might be temporary, nil error is returned without connection and
+// retry is left to caller.
+func (s *EventStream) connect() error {
+	ctx := s.req.Context()
+	if s.connected {
+		if s.MaxReconnects > 0 && s.reconnects >= s.MaxReconnects {
+			if s.lastErr != nil {
+				return fmt.Errorf("cliware: event stream reconnect limit (%d) reached: %w", s.MaxReconnects, s.lastErr)
+			}
+			return fmt.Errorf("cliware: event stream reconnect limit (%d) reached", s.MaxReconnects)
+		}
+		s.reconnects++
+		timer := time.NewTimer(s.RetryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-s.closing:
+			timer.Stop()
+			return ErrEventStreamClosed
+		}
+	} else if err := RewindableBody(s.req); err != nil {
+		return err
+	}
+	s.connected = true
+
+	req, err := CloneRequest(ctx, s.req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if s.lastID != "" {
+		req.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	resp, err := s.handler.Handle(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isTemporary(err) {
+			return err
+		}
+		s.lastErr = err
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		DrainBody(resp)
+		return fmt.Errorf("cliware: event stream got unexpected status: %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		DrainBody(resp)
+		return fmt.Errorf("cliware: event stream got unexpected content type: %q", mediaType)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		_ = resp.Body.Close()
+		return ErrEventStreamClosed
+	}
+	s.body = resp.Body
+	s.reader = bufio.NewReader(resp.Body)
+	return nil
+}
+
+// readEvent reads lines from current connection until complete event is
+// dispatched, as defined by HTML Living Standard.
+func (s *EventStream) readEvent() (Event, error) {
+	var data []string
+	event := Event{}
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			// incomplete event at the end of the stream is discarded
+			return Event{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if data == nil {
+				event = Event{}
+				continue
+			}
+			event.ID = s.lastID
+			event.Data = strings.Join(data, "\n")
+			if event.Type == "" {
+				event.Type = "message"
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				s.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+				s.RetryDelay = event.Retry
+			}
+		}
+	}
+}
+
+// isTemporary checks if error of sending request might be temporary, so
+// reconnecting makes sense. Network errors and unexpected end of response
+// are considered temporary, while errors returned by middlewares (e.g.
+// failed authentication) are not.
+func isTemporary(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,151 @@
+package cliware_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestEventStream(t *testing.T) {
+	var lastIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(lastIDs) == 1 {
+			fmt.Fprint(w, ": comment\nretry: 1\nid: 1\nevent: update\ndata: first\ndata: line\n\n")
+			fmt.Fprint(w, "id: 2\r\ndata: second\r\n\r\n")
+			fmt.Fprint(w, "data: incomplete")
+			return
+		}
+		fmt.Fprint(w, "id: 3\ndata: third\n\n")
+	}))
+	defer server.Close()
+
+	var connections int
+	chain := c.NewChain(c.RequestProcessor(func(req *http.Request) error {
+		connections++
+		return nil
+	}))
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	stream := c.NewEventStream(chain.Exec(c.HandlerFunc(sender)), req)
+	defer stream.Close()
+
+	expected := []c.Event{
+		{ID: "1", Type: "update", Data: "first\nline"},
+		{ID: "2", Type: "message", Data: "second"},
+		{ID: "3", Type: "message", Data: "third"},
+	}
+	for i, e := range expected {
+		if !stream.Next() {
+			t.Fatal("Stream ended early: ", stream.Err())
+		}
+		got := stream.Event()
+		if got.ID != e.ID || got.Type != e.Type || got.Data != e.Data {
+			t.Errorf("Event %d wrong. Got: %+v, expected: %+v", i, got, e)
+		}
+	}
+	if connections != 2 {
+		t.Errorf("Expected 2 connections through chain, got: %d", connections)
+	}
+	if len(lastIDs) != 2 || lastIDs[0] != "" || lastIDs[1] != "2" {
+		t.Errorf("Wrong Last-Event-ID headers: %v", lastIDs)
+	}
+}
+
+func TestEventStreamWrongStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	stream := c.NewEventStream(c.HandlerFunc(sender), req)
+	if stream.Next() {
+		t.Error("Expected stream to end on 404 response.")
+	}
+	if stream.Err() == nil {
+		t.Error("Expected error for 404 response.")
+	}
+}
+
+func TestEventStreamContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	var connections int
+	stream := c.NewEventStream(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		connections++
+		if connections == 3 {
+			cancel()
+		}
+		return sender(req)
+	}), req.WithContext(ctx))
+	stream.RetryDelay = 0
+	if stream.Next() {
+		t.Error("Expected no events.")
+	}
+	if stream.Err() != context.Canceled {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", context.Canceled, stream.Err())
+	}
+}
+
+func TestEventStreamCloseFromOtherGoroutine(t *testing.T) {
+	connected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		close(connected)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	stream := c.NewEventStream(c.HandlerFunc(sender), req)
+	go func() {
+		<-connected
+		stream.Close()
+	}()
+	if stream.Next() {
+		t.Error("Expected no events.")
+	}
+	if stream.Err() != c.ErrEventStreamClosed {
+		t.Errorf("Expected error: \"%s\", got: \"%v\"", c.ErrEventStreamClosed, stream.Err())
+	}
+}
+
+func TestEventStreamReconnectErrors(t *testing.T) {
+	authErr := errors.New("token refresh failed")
+	var attempts int
+	stream := c.NewEventStream(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return nil, authErr
+	}), c.EmptyRequest())
+	if stream.Next() || stream.Err() != authErr || attempts != 1 {
+		t.Errorf("Expected stream to stop on first permanent error, got %d attempts and error: %v",
+			attempts, stream.Err())
+	}
+
+	netErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	stream = c.NewEventStream(c.HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, netErr
+	}), c.EmptyRequest())
+	stream.RetryDelay = 0
+	stream.MaxReconnects = 3
+	if stream.Next() {
+		t.Error("Expected no events.")
+	}
+	if !errors.Is(stream.Err(), netErr) || !strings.Contains(stream.Err().Error(), "limit (3)") {
+		t.Errorf("Expected limit error wrapping network error, got: %v", stream.Err())
+	}
+}
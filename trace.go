@@ -0,0 +1,123 @@
+package cliware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Hop is record of single middleware execution in traced chain.
+type Hop struct {
+	// Chain is chain middleware belongs to.
+	Chain *Chain
+	// Index is position of middleware in Chain.
+	Index int
+	// Name is name given to middleware with Named, empty for unnamed ones.
+	Name string
+	// Type is Go type of middleware.
+	Type string
+
+	// Request is request middleware received.
+	Request *http.Request
+	// Response is response middleware returned.
+	Response *http.Response
+	// Err is error middleware returned.
+	Err error
+	// NextCalled is true if middleware called next handler.
+	NextCalled bool
+
+	// Start is time when middleware was entered.
+	Start time.Time
+	// End is time when middleware returned.
+	End time.Time
+}
+
+// Duration returns time spent in middleware, including time spent in
+// handlers after it.
+func (h *Hop) Duration() time.Duration {
+	return h.End.Sub(h.Start)
+}
+
+// TraceObserver receives records of middleware executions in traced chain.
+type TraceObserver interface {
+	// Observe is called after middleware returns. Hops of inner middlewares
+	// are observed before hops of middlewares that called them. It might be
+	// called concurrently for concurrent requests.
+	Observe(hop *Hop)
+}
+
+// TraceObserverFunc is function variant of TraceObserver interface.
+type TraceObserverFunc func(hop *Hop)
+
+// Observe is implementation of TraceObserver interface.
+func (f TraceObserverFunc) Observe(hop *Hop) {
+	f(hop)
+}
+
+// SetTraceObserver enables tracing of chain. Each middleware in chain, and
+// in its child chains that do not have their own observer, is wrapped and
+// its execution is reported to provided observer. Passing nil disables
+// tracing. Tracing has to be set before Exec is called.
+func (c *Chain) SetTraceObserver(observer TraceObserver) *Chain {
+	c.observer = observer
+	return c
+}
+
+// traceObserver returns observer of this chain or nearest parent that has
+// one.
+func (c *Chain) traceObserver() TraceObserver {
+	for chain := c; chain != nil; chain = chain.ParentChain() {
+		if chain.observer != nil {
+			return chain.observer
+		}
+	}
+	return nil
+}
+
+// tracedMiddleware wraps middleware and reports its executions.
+type tracedMiddleware struct {
+	chain      *Chain
+	index      int
+	middleware Middleware
+	observer   TraceObserver
+}
+
+// hopKey is context key under which flag of currently running traced
+// middleware is stored, so its next handler can mark that it was called.
+type hopKey struct {
+	m *tracedMiddleware
+}
+
+// Exec is implementation of Middleware interface.
+func (t *tracedMiddleware) Exec(next Handler) Handler {
+	handler := t.middleware.Exec(HandlerFunc(func(req *http.Request) (*http.Response, error) {
+		if req != nil {
+			if called, ok := req.Context().Value(hopKey{t}).(*int32); ok {
+				atomic.StoreInt32(called, 1)
+			}
+		}
+		return next.Handle(req)
+	}))
+	return HandlerFunc(func(req *http.Request) (resp *http.Response, err error) {
+		info := describeMiddleware(t.middleware)
+		hop := &Hop{
+			Chain:   t.chain,
+			Index:   t.index,
+			Name:    info.Name,
+			Type:    info.Type,
+			Request: req,
+			Start:   time.Now(),
+		}
+		var called int32
+		if req != nil {
+			ctx := context.WithValue(req.Context(), hopKey{t}, &called)
+			req = req.WithContext(withCurrentMiddleware(ctx, t))
+		}
+		resp, err = handler.Handle(req)
+		hop.Response, hop.Err, hop.End = resp, err, time.Now()
+		hop.NextCalled = atomic.LoadInt32(&called) == 1
+		t.observer.Observe(hop)
+		return resp, err
+	})
+}
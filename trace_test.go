@@ -0,0 +1,67 @@
+package cliware_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	c "github.com/delicb/cliware"
+)
+
+func TestTrace(t *testing.T) {
+	var mu sync.Mutex
+	var hops []*c.Hop
+	observer := c.TraceObserverFunc(func(hop *c.Hop) {
+		mu.Lock()
+		hops = append(hops, hop)
+		mu.Unlock()
+	})
+	errStop := errors.New("stop")
+	chain := c.NewChain(c.Named("pass", c.RequestProcessor(func(req *http.Request) error {
+		return nil
+	}))).SetTraceObserver(observer)
+	child := chain.ChildChain(c.RequestProcessor(func(req *http.Request) error {
+		return errStop
+	}))
+	handler, handlerCalled := createHandler()
+
+	req, _ := http.NewRequest("GET", "http://localhost", nil)
+	if _, err := child.Exec(handler).Handle(req); !errors.Is(err, errStop) {
+		t.Fatal("Expected error from processor, got: ", err)
+	}
+	if *handlerCalled {
+		t.Error("Final handler should not be called.")
+	}
+	if len(hops) != 2 {
+		t.Fatalf("Expected 2 hops, got %d", len(hops))
+	}
+
+	inner, outer := hops[0], hops[1]
+	if inner.Chain != child || inner.Index != 0 || inner.NextCalled || !errors.Is(inner.Err, errStop) {
+		t.Errorf("Unexpected inner hop: %+v", inner)
+	}
+	if outer.Chain != chain || outer.Name != "pass" || !outer.NextCalled || outer.Err != inner.Err {
+		t.Errorf("Unexpected outer hop: %+v", outer)
+	}
+	if outer.Request == nil || outer.Request.URL != req.URL {
+		t.Error("Hop does not hold request middleware received.")
+	}
+	if outer.Start.After(inner.Start) || outer.End.Before(inner.End) || outer.Duration() < inner.Duration() {
+		t.Error("Outer hop timing does not contain inner hop.")
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	var observed bool
+	chain := c.NewChain(c.RequestProcessor(func(req *http.Request) error { return nil })).
+		SetTraceObserver(c.TraceObserverFunc(func(hop *c.Hop) { observed = true })).
+		SetTraceObserver(nil)
+	handler, _ := createHandler()
+	if _, err := chain.Exec(handler).Handle(nil); err != nil {
+		t.Fatal("Handle returned error: ", err)
+	}
+	if observed {
+		t.Error("Hop observed with tracing disabled.")
+	}
+}